@@ -0,0 +1,124 @@
+// Command migrate applies the embedded schema migrations to the database of the
+// web application. It reads the same configuration file, environment and flags.
+//
+//	migrate [flags] up            apply every pending migration
+//	migrate [flags] down          roll back the last applied migration
+//	migrate [flags] to N          migrate up or down to version N, 0 rolls back everything
+//	migrate [flags] status        list the migrations and when they were applied
+//	migrate [flags] baseline N    record the migrations up to version N as applied, without running them
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/shtayeb/snippetbox/internal/config"
+	"github.com/shtayeb/snippetbox/internal/migrate"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+const usage = "usage: migrate [flags] up | down | to N | status | baseline N"
+
+func main() {
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if len(cfg.Args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Only the database settings matter here, so the rest of the configuration isn't validated
+	if cfg.DSN == "" {
+		fmt.Fprintln(os.Stderr, "invalid configuration:\ndsn: must not be empty")
+		os.Exit(2)
+	}
+
+	err = run(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config) error {
+	store, err := storage.Open(cfg.DSN, cfg.QueryTimeout)
+	if err != nil {
+		return err
+	}
+	defer store.DB.Close()
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+
+	migrator.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	// An interrupted migration is rolled back with its transaction
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	args := cfg.Args
+
+	switch {
+	case len(args) == 1 && args[0] == "up":
+		return migrator.Up(ctx)
+	case len(args) == 1 && args[0] == "down":
+		return migrator.Down(ctx)
+	case len(args) == 2 && args[0] == "to":
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("migrate: invalid version %q", args[1])
+		}
+
+		return migrator.To(ctx, version)
+	case len(args) == 1 && args[0] == "status":
+		return printStatus(ctx, migrator)
+	case len(args) == 2 && args[0] == "baseline":
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 1 {
+			return fmt.Errorf("migrate: invalid version %q", args[1])
+		}
+
+		return migrator.Baseline(ctx, version)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+
+	for _, s := range statuses {
+		applied := "pending"
+		if !s.Applied.IsZero() {
+			applied = s.Applied.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,201 @@
+// Command snippetctl runs the administrative tasks of a snippetbox instance against
+// its database. It reads the same configuration file, environment and flags as the
+// web application, followed by a command and its own flags:
+//
+//	snippetctl [flags] user create -name NAME -email EMAIL [-role ROLE] [-password PASSWORD]
+//	snippetctl [flags] snippet list -json
+//
+// Run it without a command for the full list.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/shtayeb/snippetbox/internal/config"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+type application struct {
+	users         models.UserModelInterface
+	snippets      models.SnippetModelInterface
+	loginAttempts models.LoginAttemptModelInterface
+	instance      models.InstanceModelInterface
+	stdout        io.Writer
+	stderr        io.Writer
+	// The usage line of the command being run, and its -json flag
+	usage string
+	json  bool
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(app *application, ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"user create", "-name NAME -email EMAIL [-role ROLE] [-password PASSWORD] [-json]", (*application).userCreate},
+	{"user list", "[-search TEXT] [-limit N] [-json]", (*application).userList},
+	{"user reset-password", "[-json] EMAIL", (*application).userResetPassword},
+	{"user promote", "[-role ROLE] [-json] EMAIL", (*application).userPromote},
+	{"snippet list", "[-search TEXT] [-limit N] [-json]", (*application).snippetList},
+	{"snippet delete", "ID", (*application).snippetDelete},
+	{"snippet export", "[-o FILE]", (*application).snippetExport},
+	{"purge", "[-older-than DURATION] [-json]", (*application).purge},
+	{"stats", "[-json]", (*application).stats},
+}
+
+// errUsage is returned for a bad command line, after the usage has been printed.
+var errUsage = errors.New("usage")
+
+func main() {
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stderr)
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Only the database settings matter here, so the rest of the configuration isn't validated
+	if cfg.DSN == "" {
+		fmt.Fprintln(os.Stderr, "invalid configuration:\ndsn: must not be empty")
+		os.Exit(2)
+	}
+
+	store, err := storage.Open(cfg.DSN, cfg.QueryTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	app := &application{
+		users:         store.Users,
+		snippets:      store.Snippets,
+		loginAttempts: store.LoginAttempts,
+		instance:      store.Instance,
+		stdout:        os.Stdout,
+		stderr:        os.Stderr,
+	}
+
+	// An interrupted command stops at its next query, and a transaction in flight is rolled back
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	err = app.run(ctx, cfg.Args)
+	stop()
+	store.DB.Close()
+
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// run finds the command named by the first one or two arguments and runs it with the rest.
+func (app *application) run(ctx context.Context, args []string) error {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) || strings.Join(args[:len(words)], " ") != cmd.name {
+			continue
+		}
+
+		app.usage = cmd.name + " " + cmd.usage
+
+		err := cmd.run(app, ctx, args[len(words):])
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
+		return err
+	}
+
+	printUsage(app.stderr)
+	return errUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: snippetctl [flags] command [command flags]")
+	fmt.Fprintln(w, "\ncommands:")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.usage)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nThe flags before the command are those of cmd/web, run with -h to list them.")
+}
+
+// flagSet returns the flag set of a command, with -json when the command prints
+// something structured.
+func (app *application) flagSet(name string, withJSON bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(app.stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(app.stderr, "usage: snippetctl", app.usage)
+		fs.PrintDefaults()
+	}
+
+	if withJSON {
+		fs.BoolVar(&app.json, "json", false, "Print JSON instead of a table")
+	}
+
+	return fs
+}
+
+// parse parses the flags of a command and checks the number of positional arguments.
+// Problems are reported as errUsage once the command usage has been printed.
+func (app *application) parse(fs *flag.FlagSet, args []string, positional int) error {
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if fs.NArg() != positional {
+		fmt.Fprintf(app.stderr, "%s: want %d argument(s), got %d\n", fs.Name(), positional, fs.NArg())
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// print writes v as indented JSON with -json. Otherwise it writes a table with the
+// given header, filled in by rows with one tab separated line per row.
+func (app *application) print(v any, header string, rows func(w io.Writer)) error {
+	if app.json {
+		enc := json.NewEncoder(app.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+
+	if header != "" {
+		fmt.Fprintln(tw, header)
+	}
+	rows(tw)
+
+	return tw.Flush()
+}
+
+const timeFormat = "2006-01-02 15:04"
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models/mocks"
+)
+
+func newTestApplication() (*application, *bytes.Buffer) {
+	var stdout bytes.Buffer
+
+	return &application{
+		users:         &mocks.UserModel{},
+		snippets:      &mocks.SnippetModel{},
+		loginAttempts: &mocks.LoginAttemptModel{},
+		instance:      &mocks.InstanceModel{},
+		stdout:        &stdout,
+		stderr:        &bytes.Buffer{},
+	}, &stdout
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    error
+		wantOutput []string
+	}{
+		{
+			name:       "Promote",
+			args:       []string{"user", "promote", "alice@example.com"},
+			wantOutput: []string{"alice@example.com", "admin"},
+		},
+		{
+			name:       "Promote to moderator",
+			args:       []string{"user", "promote", "-role", "moderator", "alice@example.com"},
+			wantOutput: []string{"moderator"},
+		},
+		{
+			name:    "Promote to unknown role",
+			args:    []string{"user", "promote", "-role", "owner", "alice@example.com"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "Promote unknown user",
+			args:    []string{"user", "promote", "nobody@example.com"},
+			wantErr: errors.New("no user with email address nobody@example.com"),
+		},
+		{
+			name:       "Reset password",
+			args:       []string{"user", "reset-password", "alice@example.com"},
+			wantOutput: []string{"reset required", "password: "},
+		},
+		{
+			name:    "Create with duplicate email",
+			args:    []string{"user", "create", "-name", "Dupe", "-email", "dupe@example.com"},
+			wantErr: errors.New("email address dupe@example.com is already in use"),
+		},
+		{
+			name:    "Create with invalid email",
+			args:    []string{"user", "create", "-name", "Bob", "-email", "bob"},
+			wantErr: errUsage,
+		},
+		{
+			name:       "List snippets",
+			args:       []string{"snippet", "list"},
+			wantOutput: []string{"An old silent pond", "hidden"},
+		},
+		{
+			name:    "Delete unknown snippet",
+			args:    []string{"snippet", "delete", "2"},
+			wantErr: errors.New("no snippet with ID 2"),
+		},
+		{
+			name:    "Delete without ID",
+			args:    []string{"snippet", "delete"},
+			wantErr: errUsage,
+		},
+		{
+			name:       "Purge",
+			args:       []string{"purge"},
+			wantOutput: []string{"expired snippets", "2", "expired sessions", "7"},
+		},
+		{
+			name:       "Stats",
+			args:       []string{"stats"},
+			wantOutput: []string{"users", "active sessions"},
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"user", "delete", "alice@example.com"},
+			wantErr: errUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, stdout := newTestApplication()
+
+			err := app.run(context.Background(), tt.args)
+
+			switch {
+			case tt.wantErr == nil:
+				assert.NilError(t, err)
+			case errors.Is(tt.wantErr, errUsage):
+				assert.Equal(t, errors.Is(err, errUsage), true)
+			case err == nil:
+				t.Fatalf("got: nil; want: %v", tt.wantErr)
+			default:
+				assert.Equal(t, err.Error(), tt.wantErr.Error())
+			}
+
+			for _, want := range tt.wantOutput {
+				assert.StringContains(t, stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	t.Run("Stats", func(t *testing.T) {
+		app, stdout := newTestApplication()
+
+		err := app.run(context.Background(), []string{"stats", "-json"})
+		assert.NilError(t, err)
+
+		var stats statsResult
+		err = json.Unmarshal(stdout.Bytes(), &stats)
+		assert.NilError(t, err)
+		assert.Equal(t, stats.Users, 3)
+		assert.Equal(t, stats.Sessions, 4)
+	})
+
+	t.Run("Snippet list", func(t *testing.T) {
+		app, stdout := newTestApplication()
+
+		err := app.run(context.Background(), []string{"snippet", "list", "-json"})
+		assert.NilError(t, err)
+
+		var snippets []snippet
+		err = json.Unmarshal(stdout.Bytes(), &snippets)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 2)
+		// Content is left out of listings
+		assert.Equal(t, snippets[0].Content, "")
+	})
+
+	t.Run("Reset password", func(t *testing.T) {
+		app, stdout := newTestApplication()
+
+		err := app.run(context.Background(), []string{"user", "reset-password", "-json", "alice@example.com"})
+		assert.NilError(t, err)
+
+		var u user
+		err = json.Unmarshal(stdout.Bytes(), &u)
+		assert.NilError(t, err)
+		assert.Equal(t, u.Email, "alice@example.com")
+		assert.Equal(t, len(u.Password), 16)
+		assert.Equal(t, strings.Contains(stdout.String(), "hashed"), false)
+	})
+}
+
+func TestSnippetExport(t *testing.T) {
+	app, _ := newTestApplication()
+	path := filepath.Join(t.TempDir(), "snippets.json")
+
+	err := app.run(context.Background(), []string{"snippet", "export", "-o", path})
+	assert.NilError(t, err)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snippets []snippet
+	err = json.Unmarshal(b, &snippets)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Content, "An old silent pond...")
+	assert.Equal(t, snippets[1].Hidden, true)
+}
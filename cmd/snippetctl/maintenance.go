@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+type purgeResult struct {
+	Snippets      int `json:"snippets"`
+	Sessions      int `json:"sessions"`
+	LoginAttempts int `json:"login_attempts"`
+}
+
+// purge deletes expired snippets and sessions, and the login attempts and lockouts
+// older than -older-than. The web application only looks back an hour or so for
+// login throttling, the rest is kept for auditing.
+func (app *application) purge(ctx context.Context, args []string) error {
+	fs := app.flagSet("purge", true)
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "Age of the login attempts and lockouts to delete")
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *olderThan <= 0 {
+		fmt.Fprintln(app.stderr, "-older-than: must be positive")
+		return errUsage
+	}
+
+	var result purgeResult
+
+	result.Snippets, err = app.snippets.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	result.Sessions, err = app.instance.PurgeSessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	result.LoginAttempts, err = app.loginAttempts.Purge(ctx, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+
+	return app.print(result, "DELETED\tROWS", func(w io.Writer) {
+		fmt.Fprintf(w, "expired snippets\t%d\n", result.Snippets)
+		fmt.Fprintf(w, "expired sessions\t%d\n", result.Sessions)
+		fmt.Fprintf(w, "login attempts and lockouts\t%d\n", result.LoginAttempts)
+	})
+}
+
+type statsResult struct {
+	Users           int `json:"users"`
+	DisabledUsers   int `json:"disabled_users"`
+	Moderators      int `json:"moderators"`
+	Admins          int `json:"admins"`
+	Snippets        int `json:"snippets"`
+	ExpiredSnippets int `json:"expired_snippets"`
+	HiddenSnippets  int `json:"hidden_snippets"`
+	OpenReports     int `json:"open_reports"`
+	Sessions        int `json:"sessions"`
+}
+
+func (app *application) stats(ctx context.Context, args []string) error {
+	fs := app.flagSet("stats", true)
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	s, err := app.instance.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := statsResult(*s)
+
+	return app.print(result, "", func(w io.Writer) {
+		fmt.Fprintf(w, "users\t%d\n", s.Users)
+		fmt.Fprintf(w, "  disabled\t%d\n", s.DisabledUsers)
+		fmt.Fprintf(w, "  moderators\t%d\n", s.Moderators)
+		fmt.Fprintf(w, "  admins\t%d\n", s.Admins)
+		fmt.Fprintf(w, "snippets\t%d\n", s.Snippets)
+		fmt.Fprintf(w, "  expired\t%d\n", s.ExpiredSnippets)
+		fmt.Fprintf(w, "  hidden\t%d\n", s.HiddenSnippets)
+		fmt.Fprintf(w, "reported snippets\t%d\n", s.OpenReports)
+		fmt.Fprintf(w, "active sessions\t%d\n", s.Sessions)
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// snippet is the output form of models.Snippet. The content is only part of exports.
+type snippet struct {
+	ID      int    `json:"id"`
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Content string `json:"content,omitempty"`
+	// Part of listings too, it says how the content is shown
+	ContentType string    `json:"content_type"`
+	UserID      int       `json:"user_id,omitempty"`
+	Hidden      bool      `json:"hidden"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+}
+
+func newSnippet(s *models.Snippet, withContent bool) *snippet {
+	out := &snippet{
+		ID:          s.ID,
+		Slug:        s.Slug,
+		Title:       s.Title,
+		ContentType: s.ContentType,
+		UserID:      s.UserID,
+		Hidden:      s.Hidden,
+		Created:     s.Created,
+		Expires:     s.Expires,
+	}
+
+	if withContent {
+		out.Content = s.Content
+	}
+
+	return out
+}
+
+func (app *application) snippetList(ctx context.Context, args []string) error {
+	fs := app.flagSet("snippet list", true)
+	search := fs.String("search", "", "Only list snippets whose title or content contains this")
+	limit := fs.Int("limit", 50, "Maximum number of snippets to list")
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	snippets, total, err := app.snippets.List(ctx, *search, *limit, 0)
+	if err != nil {
+		return err
+	}
+
+	out := make([]*snippet, len(snippets))
+	for i, s := range snippets {
+		out[i] = newSnippet(s, false)
+	}
+
+	now := time.Now()
+
+	return app.print(out, "ID\tSLUG\tTITLE\tOWNER\tSTATUS\tCREATED\tEXPIRES", func(w io.Writer) {
+		for _, s := range out {
+			owner := "-"
+			if s.UserID != 0 {
+				owner = strconv.Itoa(s.UserID)
+			}
+
+			status := "live"
+			switch {
+			case s.Hidden:
+				status = "hidden"
+			case !s.Expires.After(now):
+				status = "expired"
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Slug, s.Title, owner, status,
+				s.Created.Format(timeFormat), s.Expires.Format(timeFormat))
+		}
+
+		if total > len(out) {
+			fmt.Fprintf(w, "\n%d of %d snippets, raise -limit to see more\n", len(out), total)
+		}
+	})
+}
+
+func (app *application) snippetDelete(ctx context.Context, args []string) error {
+	fs := app.flagSet("snippet delete", false)
+
+	err := app.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || id < 1 {
+		fmt.Fprintf(app.stderr, "snippet delete: invalid ID %q\n", fs.Arg(0))
+		return errUsage
+	}
+
+	err = app.snippets.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("no snippet with ID %d", id)
+		}
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "deleted snippet %d\n", id)
+
+	return nil
+}
+
+// exportPageSize is how many snippets are read from the database at a time.
+const exportPageSize = 100
+
+// snippetExport writes every snippet, expired and hidden ones included, as a JSON
+// array, to the -o file or standard output.
+func (app *application) snippetExport(ctx context.Context, args []string) error {
+	fs := app.flagSet("snippet export", false)
+	output := fs.String("o", "", "File to write, standard output when empty")
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = app.writeSnippets(ctx, app.stdout)
+		return err
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+
+	count, err := app.writeSnippets(ctx, f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// A failure to close can lose what was written, so the export only succeeds after it
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "exported %d snippets to %s\n", count, *output)
+
+	return nil
+}
+
+// writeSnippets writes the JSON array of snippetExport and returns how many snippets it
+// holds. They are read and written a page at a time so the whole table never has to fit
+// in memory. The pages follow the IDs, so snippets added meanwhile don't shift them.
+func (app *application) writeSnippets(ctx context.Context, w io.Writer) (int, error) {
+	_, err := io.WriteString(w, "[")
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	lastID := 0
+
+	for {
+		snippets, err := app.snippets.After(ctx, lastID, exportPageSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, s := range snippets {
+			b, err := json.Marshal(newSnippet(s, true))
+			if err != nil {
+				return 0, err
+			}
+
+			sep := ",\n"
+			if count == 0 {
+				sep = "\n"
+			}
+
+			_, err = fmt.Fprintf(w, "%s  %s", sep, b)
+			if err != nil {
+				return 0, err
+			}
+
+			count++
+			lastID = s.ID
+		}
+
+		if len(snippets) < exportPageSize {
+			break
+		}
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
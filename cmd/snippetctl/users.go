@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// user is the output form of models.User, without the password hash.
+type user struct {
+	ID                    int       `json:"id"`
+	Name                  string    `json:"name"`
+	Email                 string    `json:"email"`
+	Role                  string    `json:"role"`
+	Disabled              bool      `json:"disabled"`
+	PasswordResetRequired bool      `json:"password_reset_required"`
+	Created               time.Time `json:"created"`
+	// Only set when snippetctl chose the password
+	Password string `json:"password,omitempty"`
+}
+
+func newUser(u *models.User) *user {
+	return &user{
+		ID:                    u.ID,
+		Name:                  u.Name,
+		Email:                 u.Email,
+		Role:                  u.Role,
+		Disabled:              u.Disabled,
+		PasswordResetRequired: u.PasswordResetRequired,
+		Created:               u.Created,
+	}
+}
+
+const userHeader = "ID\tNAME\tEMAIL\tROLE\tSTATUS\tCREATED"
+
+func (u *user) row(w io.Writer) {
+	status := "active"
+	switch {
+	case u.Disabled:
+		status = "disabled"
+	case u.PasswordResetRequired:
+		status = "reset required"
+	}
+
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, u.Role, status, u.Created.Format(timeFormat))
+}
+
+// printUser prints a single user, followed by the password when one was generated.
+func (app *application) printUser(u *user) error {
+	return app.print(u, userHeader, func(w io.Writer) {
+		u.row(w)
+
+		if u.Password != "" {
+			fmt.Fprintf(w, "\npassword: %s (must be changed at the next login)\n", u.Password)
+		}
+	})
+}
+
+// userCreate adds an account. Without -password a random one is generated and printed,
+// and the user has to replace it when they first log in.
+func (app *application) userCreate(ctx context.Context, args []string) error {
+	fs := app.flagSet("user create", true)
+	name := fs.String("name", "", "Name of the user")
+	email := fs.String("email", "", "Email address of the user")
+	role := fs.String("role", models.RoleUser, "Role of the user (user, moderator or admin)")
+	password := fs.String("password", "", "Password of the user, generated when empty")
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		*password, err = models.NewTemporaryPassword()
+		if err != nil {
+			return err
+		}
+	}
+
+	// The same rules as the signup form
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(*name), "name", "This field cannot be blank")
+	v.CheckField(validator.Matches(*email, validator.EmailRX), "email", "This field must be a valid email address")
+	v.CheckField(validator.MinChars(*password, 8), "password", "This field must be at least 8 characters long")
+	v.CheckField(models.ValidRole(*role), "role", "This field must be user, moderator or admin")
+
+	if !v.Valid() {
+		for field, message := range v.FieldErrors {
+			fmt.Fprintf(app.stderr, "-%s: %s\n", field, message)
+		}
+		return errUsage
+	}
+
+	err = app.users.Insert(ctx, *name, *email, *password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			return fmt.Errorf("email address %s is already in use", *email)
+		}
+		return err
+	}
+
+	u, err := app.users.GetByEmail(ctx, *email)
+	if err != nil {
+		return err
+	}
+
+	if u.Role != *role {
+		err = app.users.SetRole(ctx, u.ID, *role)
+		if err != nil {
+			return err
+		}
+		u.Role = *role
+	}
+
+	out := newUser(u)
+
+	if generated {
+		err = app.users.RequirePasswordReset(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+
+		out.PasswordResetRequired = true
+		out.Password = *password
+	}
+
+	return app.printUser(out)
+}
+
+func (app *application) userList(ctx context.Context, args []string) error {
+	fs := app.flagSet("user list", true)
+	search := fs.String("search", "", "Only list users whose name or email contains this")
+	limit := fs.Int("limit", 50, "Maximum number of users to list")
+
+	err := app.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	users, total, err := app.users.List(ctx, *search, *limit, 0)
+	if err != nil {
+		return err
+	}
+
+	out := make([]*user, len(users))
+	for i, u := range users {
+		out[i] = newUser(u)
+	}
+
+	return app.print(out, userHeader, func(w io.Writer) {
+		for _, u := range out {
+			u.row(w)
+		}
+
+		if total > len(out) {
+			fmt.Fprintf(w, "\n%d of %d users, raise -limit to see more\n", len(out), total)
+		}
+	})
+}
+
+// userResetPassword replaces the password of a user with a random one that they
+// have to change at their next login.
+func (app *application) userResetPassword(ctx context.Context, args []string) error {
+	fs := app.flagSet("user reset-password", true)
+
+	err := app.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	u, err := app.getUser(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	password, err := models.NewTemporaryPassword()
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetPassword(ctx, u.ID, password)
+	if err != nil {
+		return err
+	}
+
+	out := newUser(u)
+	out.PasswordResetRequired = true
+	out.Password = password
+
+	return app.printUser(out)
+}
+
+func (app *application) userPromote(ctx context.Context, args []string) error {
+	fs := app.flagSet("user promote", true)
+	role := fs.String("role", models.RoleAdmin, "New role of the user (user, moderator or admin)")
+
+	err := app.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if !models.ValidRole(*role) {
+		fmt.Fprintln(app.stderr, "-role: This field must be user, moderator or admin")
+		return errUsage
+	}
+
+	u, err := app.getUser(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetRole(ctx, u.ID, *role)
+	if err != nil {
+		return err
+	}
+
+	u.Role = *role
+
+	return app.printUser(newUser(u))
+}
+
+func (app *application) getUser(ctx context.Context, email string) (*models.User, error) {
+	u, err := app.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, fmt.Errorf("no user with email address %s", email)
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// Number of rows on each page of the admin listings
+const adminPageSize = 20
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	p := newPagination(r, adminPageSize)
+
+	users, total, err := app.users.List(r.Context(), p.Query, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "admin_users.tmpl", data)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	p := newPagination(r, adminPageSize)
+
+	snippets, total, err := app.snippets.List(r.Context(), p.Query, p.PageSize, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = p
+
+	app.render(w, r, http.StatusOK, "admin_snippets.tmpl", data)
+}
+
+func (app *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserAction(w, r, func(ctx context.Context, id int) error {
+		return app.users.SetDisabled(ctx, id, true)
+	}, "The account has been disabled.")
+}
+
+func (app *application) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserAction(w, r, func(ctx context.Context, id int) error {
+		return app.users.SetDisabled(ctx, id, false)
+	}, "The account has been enabled.")
+}
+
+// adminUserResetPasswordPost replaces the password of a user with a temporary one and
+// logs the user out everywhere. The old password is no use to whoever may know it, and
+// the user doesn't need to remember it. The temporary password is shown once in the
+// response, for the admin to pass on, and is never stored in the session.
+func (app *application) adminUserResetPasswordPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	password, err := models.NewTemporaryPassword()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.users.SetPassword(r.Context(), id, password)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.endSessions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.TemporaryPassword = password
+
+	// Nothing along the way should keep the page with the password
+	w.Header().Set("Cache-Control", "no-store")
+
+	app.render(w, r, http.StatusOK, "admin_password.tmpl", data)
+}
+
+// adminUserID reads the :id parameter of an admin action on a user, writing a 404 if it
+// isn't valid. Admins can't act on their own account, so they can't lock themselves out.
+func (app *application) adminUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return 0, false
+	}
+
+	if id == app.authenticatedUser(r).ID {
+		app.clientError(w, r, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// adminUserAction runs an action against the user named by the :id parameter and
+// sends the admin back to the user listing.
+func (app *application) adminUserAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id int) error, flash string) {
+	id, ok := app.adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	err := action(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	// Read what the webhooks are told about before it is gone. An expired snippet
+	// isn't found, and has no event.
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	if snippet != nil {
+		app.snippetDeletedEvent(r, snippet)
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been deleted.")
+
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
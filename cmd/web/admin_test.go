@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestAdminUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Anonymous",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Regular user",
+			email:    "alice@example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			email:    "admin@example.com",
+			wantCode: http.StatusOK,
+			wantBody: "alice@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "pa$$word")
+			}
+
+			code, _, body := ts.get(t, "/admin/users?q=alice&page=1")
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminUserDisablePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/admin/users")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Other user",
+			urlPath:  "/admin/users/1/disable",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Own account",
+			urlPath:  "/admin/users/2/disable",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/admin/users/99/disable",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAdminUserResetPasswordPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Alice is logged in on one browser when the admin resets her password on another
+	ts.login(t, "alice@example.com", "pa$$word")
+	aliceJar := ts.Client().Jar
+
+	jar, err := cookiejar.New(nil)
+	assert.NilError(t, err)
+	ts.Client().Jar = jar
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/admin/users")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// The password is in the response itself, not in a flash kept in the session store
+	code, headers, body := ts.postForm(t, "/admin/users/1/reset-password", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "no-store")
+	assert.StringContains(t, body, "The password of Alice (alice@example.com) has been reset")
+	assert.Equal(t, regexp.MustCompile(`value='[\w-]{16}' readonly`).MatchString(body), true)
+
+	_, _, body = ts.get(t, "/admin/users")
+	assert.Equal(t, strings.Contains(body, "temporary"), false)
+
+	ts.Client().Jar = aliceJar
+
+	code, headers, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login?next=/account/view")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// The decisions about who may see and change what are all made here, so that the
+// handlers only ask. The site roles of a user (user, moderator, admin) are checked by
+// requireRole on whole routes, the organization roles depend on the organization so
+// they are checked against the membership of the user in it:
+//
+//	member      creates snippets for the organization and edits the ones they created
+//	maintainer  edits every snippet of the organization, invites members and maintainers,
+//	            and manages the webhooks of the organization
+//	owner       invites with any role, changes the roles of members and removes them
+
+// canViewSnippet reports whether the snippet can be shown to the current user. Snippets
+// hidden by a moderator are only shown to their owner and to moderators.
+func (app *application) canViewSnippet(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Hidden {
+		return true
+	}
+
+	user := app.authenticatedUser(r)
+
+	return user != nil && (user.ID == snippet.UserID || user.HasRole(models.RoleModerator))
+}
+
+// canEditSnippet reports whether the current user may change the snippet. A personal
+// snippet can only be edited by its owner, the snippet of an organization by the
+// member who created it and by the maintainers of the organization.
+func (app *application) canEditSnippet(r *http.Request, snippet *models.Snippet) (bool, error) {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false, nil
+	}
+
+	if snippet.OrgID == 0 {
+		return user.ID == snippet.UserID, nil
+	}
+
+	membership, err := app.membership(r, snippet.OrgID)
+	if err != nil || membership == nil {
+		return false, err
+	}
+
+	return user.ID == snippet.UserID || membership.HasRole(models.OrgRoleMaintainer), nil
+}
+
+// canViewCollection reports whether the collection can be shown to the current user.
+// Private collections are only shown to their owner.
+func (app *application) canViewCollection(r *http.Request, collection *models.Collection) bool {
+	return collection.IsPublic() || app.ownsCollection(r, collection)
+}
+
+// ownsCollection reports whether the current user is the owner of the collection, the
+// only one who may change it.
+func (app *application) ownsCollection(r *http.Request, collection *models.Collection) bool {
+	user := app.authenticatedUser(r)
+
+	return user != nil && user.ID == collection.UserID
+}
+
+// membership returns the membership of the current user in an organization, or nil if
+// nobody is logged in or the user isn't a member.
+func (app *application) membership(r *http.Request, orgID int) (*models.Membership, error) {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return nil, nil
+	}
+
+	membership, err := app.organizations.Member(r.Context(), orgID, user.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil, nil
+	}
+
+	return membership, err
+}
+
+// canCreateOrgSnippet reports whether a member may create snippets for their organization,
+// which every member may.
+func canCreateOrgSnippet(membership *models.Membership) bool {
+	return membership != nil
+}
+
+// canInvite reports whether a member may invite someone to join their organization with
+// the given role. Maintainers invite up to their own role, owners with any role.
+func canInvite(membership *models.Membership, role string) bool {
+	return membership != nil && membership.HasRole(models.OrgRoleMaintainer) && membership.HasRole(role)
+}
+
+// canManageMembers reports whether a member may change the roles of the other members
+// of their organization.
+func canManageMembers(membership *models.Membership) bool {
+	return membership != nil && membership.HasRole(models.OrgRoleOwner)
+}
+
+// canRemoveMember reports whether a member may take userID out of their organization.
+// Owners remove anyone, and everyone may leave.
+func canRemoveMember(membership *models.Membership, userID int) bool {
+	return membership != nil && (membership.UserID == userID || membership.HasRole(models.OrgRoleOwner))
+}
+
+// canManageWebhooks reports whether a member may add and remove the webhooks of their
+// organization, and see their secrets and deliveries.
+func canManageWebhooks(membership *models.Membership) bool {
+	return membership != nil && membership.HasRole(models.OrgRoleMaintainer)
+}
+
+// canManageWebhook reports whether the current user may see and change the webhook. A
+// personal webhook is only for its user, that of an organization for the members who
+// manage its webhooks.
+func (app *application) canManageWebhook(r *http.Request, webhook *models.Webhook) (bool, error) {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false, nil
+	}
+
+	if webhook.OrgID == 0 {
+		return user.ID == webhook.UserID, nil
+	}
+
+	membership, err := app.membership(r, webhook.OrgID)
+	if err != nil {
+		return false, err
+	}
+
+	return canManageWebhooks(membership), nil
+}
+
+// canAcceptInvitation reports whether the current user may accept the invitation, which
+// was sent to the email address of their account.
+func (app *application) canAcceptInvitation(r *http.Request, invitation *models.Invitation) bool {
+	user := app.authenticatedUser(r)
+
+	return user != nil && strings.EqualFold(user.Email, invitation.Email)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// The actions on a snippet of a collection, in the :action parameter of the route
+const (
+	collectionRemove   = "remove"
+	collectionMoveUp   = "up"
+	collectionMoveDown = "down"
+)
+
+type collectionForm struct {
+	Title               string `form:"title"`
+	Description         string `form:"description"`
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// check validates the fields of the form, which are the same for a new collection and an edited one.
+func (form *collectionForm) check() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.MaxChars(form.Description, 1000), "description", "This field cannot be more than 1000 characters long")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityPrivate), "visibility", "This field must equal public or private")
+}
+
+// viewableCollection loads the collection named by the :id parameter of the route, writing
+// a 404 if it doesn't exist or is private and the current user isn't its owner.
+func (app *application) viewableCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	collection, err := app.collections.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	if !app.canViewCollection(r, collection) {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// ownedCollection is viewableCollection for the changes that only the owner of the
+// collection may make. Other users get a 403 for a public collection.
+func (app *application) ownedCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	collection, ok := app.viewableCollection(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !app.ownsCollection(r, collection) {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// viewableCollectionSnippets returns the snippets of a collection that the current user
+// may see, in their order.
+func (app *application) viewableCollectionSnippets(r *http.Request, collection *models.Collection) ([]*models.Snippet, error) {
+	snippets, err := app.collections.Snippets(r.Context(), collection.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	viewable := []*models.Snippet{}
+
+	for _, s := range snippets {
+		if app.canViewSnippet(r, s) {
+			viewable = append(viewable, s)
+		}
+	}
+
+	return viewable, nil
+}
+
+func (app *application) collectionCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = collectionForm{Visibility: models.VisibilityPrivate}
+
+	app.render(w, r, http.StatusOK, "collection_form.tmpl", data)
+}
+
+func (app *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.check()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "collection_form.tmpl", data)
+		return
+	}
+
+	id, err := app.collections.Insert(r.Context(), app.authenticatedUser(r).ID, form.Title, form.Description, form.Visibility)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collections/%d", id), http.StatusSeeOther)
+}
+
+func (app *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.viewableCollection(w, r)
+	if !ok {
+		return
+	}
+
+	snippets, err := app.viewableCollectionSnippets(r, collection)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = snippets
+
+	// The owner is named on the page, unless the account is gone
+	data.User, err = app.users.Get(r.Context(), collection.UserID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "collection.tmpl", data)
+}
+
+// collectionExportJSON is the file that a collection is exported to, the collection
+// with the content of its snippets.
+type collectionExportJSON struct {
+	*collectionJSON
+	Snippets []*snippetJSON `json:"snippets"`
+}
+
+// collectionExport downloads a collection and the snippets in it that the current user
+// may see as a JSON file.
+func (app *application) collectionExport(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.viewableCollection(w, r)
+	if !ok {
+		return
+	}
+
+	snippets, err := app.viewableCollectionSnippets(r, collection)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	export := collectionExportJSON{
+		collectionJSON: newCollectionJSON(collection),
+		Snippets:       mapJSON(snippets, newSnippetJSON),
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="collection-%d.json"`, collection.ID))
+
+	app.writeJSON(w, http.StatusOK, export)
+}
+
+func (app *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Form = collectionForm{
+		Title:       collection.Title,
+		Description: collection.Description,
+		Visibility:  collection.Visibility,
+	}
+
+	app.render(w, r, http.StatusOK, "collection_form.tmpl", data)
+}
+
+func (app *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.check()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Collection = collection
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "collection_form.tmpl", data)
+		return
+	}
+
+	err = app.collections.Update(r.Context(), collection.ID, form.Title, form.Description, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collections/%d", collection.ID), http.StatusSeeOther)
+}
+
+func (app *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.collections.Delete(r.Context(), collection.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Collection %q deleted.", collection.Title))
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// collectionSnippetPost applies the :action parameter (remove, up or down) to the snippet
+// named by the :slug parameter in the collection named by :id.
+func (app *application) collectionSnippetPost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	action := params.ByName("action")
+	if !validator.PermittedValue(action, collectionRemove, collectionMoveUp, collectionMoveDown) {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.GetBySlug(r.Context(), params.ByName("slug"))
+	if err == nil {
+		switch action {
+		case collectionRemove:
+			err = app.collections.RemoveSnippet(r.Context(), collection.ID, snippet.ID)
+		default:
+			err = app.collections.MoveSnippet(r.Context(), collection.ID, snippet.ID, action == collectionMoveUp)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collections/%d", collection.ID), http.StatusSeeOther)
+}
+
+type snippetCollectForm struct {
+	CollectionID int `form:"collection"`
+}
+
+// snippetCollectPost adds the snippet named by the :slug parameter to one of the
+// collections of the current user, picked on the snippet page.
+func (app *application) snippetCollectPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCollectForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.CollectionID < 1 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := app.collections.Get(r.Context(), form.CollectionID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	// Snippets can only be added to collections of one's own
+	if !app.ownsCollection(r, collection) {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.collections.AddSnippet(r.Context(), collection.ID, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet added to %q.", collection.Title))
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestCollectionView(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		notWantBody []string
+	}{
+		{
+			name:        "Public collection",
+			urlPath:     "/collections/1",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"<h2>Haiku</h2>", "By Alice", "Poems about ponds", "<a href='/s/pond-Xq7'>An old silent pond</a>"},
+			notWantBody: []string{"A hidden frog", "Delete collection"},
+		},
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"A hidden frog", "<form action='/collections/1/snippets/pond-Xq7/up' method='POST'>", "Delete collection"},
+		},
+		{
+			name:     "Private collection",
+			urlPath:  "/collections/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private collection of another user",
+			email:    "mod@example.com",
+			urlPath:  "/collections/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private collection of the owner",
+			email:    "alice@example.com",
+			urlPath:  "/collections/2",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<h2>Drafts</h2>", "only visible to you"},
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/collections/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/collections/haiku",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "pa$$word")
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+
+			for _, notWant := range tt.notWantBody {
+				assert.Equal(t, strings.Contains(body, notWant), false)
+			}
+		})
+	}
+}
+
+func TestCollectionExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/collections/1/export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	assert.Equal(t, headers.Get("Content-Disposition"), `attachment; filename="collection-1.json"`)
+
+	var export struct {
+		Title    string         `json:"title"`
+		Snippets []*snippetJSON `json:"snippets"`
+	}
+
+	err := json.Unmarshal([]byte(body), &export)
+	assert.NilError(t, err)
+	assert.Equal(t, export.Title, "Haiku")
+
+	// The hidden snippet is only exported for those who may see it
+	assert.Equal(t, len(export.Snippets), 1)
+	assert.Equal(t, export.Snippets[0].Slug, "pond-Xq7")
+	assert.Equal(t, export.Snippets[0].Content, "An old silent pond...")
+
+	code, _, _ = ts.get(t, "/collections/2/export")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCollectionCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/collection/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login?next=/collection/create")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/collection/create")
+	assert.StringContains(t, body, "<form action='/collection/create' method='POST'>")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		title        string
+		visibility   string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid",
+			title:        "Deploy scripts",
+			visibility:   "public",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/collections/3",
+		},
+		{
+			name:       "Empty title",
+			title:      "",
+			visibility: "private",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid visibility",
+			title:      "Deploy scripts",
+			visibility: "friends",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("description", "")
+			form.Add("visibility", tt.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/collection/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestCollectionOwnerPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner deletes",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1/delete",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Public collection of another user",
+			email:    "mod@example.com",
+			urlPath:  "/collections/1/delete",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Private collection of another user",
+			email:    "mod@example.com",
+			urlPath:  "/collections/2/delete",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Owner moves a snippet",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1/snippets/pond-Xq7/down",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Owner removes a snippet",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1/snippets/frog_K2m/remove",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Unknown action",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1/snippets/pond-Xq7/sideways",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent snippet",
+			email:    "alice@example.com",
+			urlPath:  "/collections/1/snippets/missing1/remove",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Other user moves a snippet",
+			email:    "admin@example.com",
+			urlPath:  "/collections/1/snippets/pond-Xq7/up",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/s/pond-Xq7")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetCollections(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users only see the public collections of a snippet, and can't add it to one
+	_, _, body := ts.get(t, "/s/pond-Xq7")
+	assert.StringContains(t, body, "<a href='/collections/1'>Haiku</a>")
+	assert.Equal(t, strings.Contains(body, "Drafts"), false)
+	assert.Equal(t, strings.Contains(body, "Add to collection"), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/s/pond-Xq7")
+	assert.StringContains(t, body, "<a href='/collections/2'>Drafts</a>")
+	assert.StringContains(t, body, "<form class='collect' action='/s/pond-Xq7/collections' method='POST'>")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		collection   string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid",
+			urlPath:      "/s/pond-Xq7/collections",
+			collection:   "2",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/s/pond-Xq7",
+		},
+		{
+			name:       "Non-existent collection",
+			urlPath:    "/s/pond-Xq7/collections",
+			collection: "99",
+			wantCode:   http.StatusNotFound,
+		},
+		{
+			name:       "Missing collection",
+			urlPath:    "/s/pond-Xq7/collections",
+			collection: "",
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			name:       "Non-existent snippet",
+			urlPath:    "/s/missing1/collections",
+			collection: "2",
+			wantCode:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("collection", tt.collection)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetCollectOtherUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "mod@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/s/pond-Xq7")
+
+	form := url.Values{}
+	form.Add("collection", "1")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// A public collection of Alice's can be seen, but not added to
+	code, _, _ := ts.postForm(t, "/s/pond-Xq7/collections", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCollectionFlow(t *testing.T) {
+	// The memory backend keeps what the requests write, so the order of the snippets can be followed
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	err := app.users.Insert(context.Background(), "Bob", "bob@example.com", "validPa$$word")
+	assert.NilError(t, err)
+
+	ts.login(t, "bob@example.com", "validPa$$word")
+
+	var slugs []string
+
+	for _, title := range []string{"Deploy", "Rollback"} {
+		_, slug, err := app.snippets.Insert(context.Background(), 1, 0, title, title+" the site", "text", 7)
+		assert.NilError(t, err)
+
+		slugs = append(slugs, slug)
+	}
+
+	_, _, body := ts.get(t, "/collection/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("title", "Ops")
+	form.Add("description", "Keeping the site up")
+	form.Add("visibility", "private")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/collection/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/collections/1")
+
+	for _, slug := range slugs {
+		form = url.Values{}
+		form.Add("collection", "1")
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ = ts.postForm(t, "/s/"+slug+"/collections", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	_, _, body = ts.get(t, "/collections/1")
+	assert.Equal(t, strings.Index(body, "Deploy") < strings.Index(body, "Rollback"), true)
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/collections/1/snippets/"+slugs[1]+"/up", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/collections/1")
+	assert.Equal(t, strings.Index(body, "Rollback") < strings.Index(body, "Deploy"), true)
+
+	// The account page lists the collection, and the snippet page names it
+	_, _, body = ts.get(t, "/account/view")
+	assert.StringContains(t, body, "<a href='/collections/1'>Ops</a>")
+
+	_, _, body = ts.get(t, "/s/"+slugs[0])
+	assert.StringContains(t, body, "<a href='/collections/1'>Ops</a>")
+
+	// Private collections are only found by their owner
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.get(t, "/collections/1")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// The events of a snippet are sent to its webhooks in the background, so that the
+// request that changed the snippet doesn't wait for them. Each delivery is recorded
+// before its first attempt, and every attempt updates it, so the delivery log shows
+// what happened even to the deliveries that shutdown cut short.
+
+// errPrivateAddress is the error of a delivery to an address that isn't public.
+var errPrivateAddress = errors.New("the address of the webhook is not public")
+
+// webhookSender makes the HTTP requests of the deliveries.
+type webhookSender struct {
+	client *http.Client
+	// Lets deliveries reach loopback and private addresses, only for the tests
+	allowPrivate bool
+	// How many times a delivery is tried, and the wait before the first retry, which
+	// doubles after each one
+	attempts int
+	backoff  time.Duration
+	// Closed by Stop, to end the waits before a retry
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newWebhookSender() *webhookSender {
+	s := &webhookSender{
+		attempts: 5,
+		backoff:  30 * time.Second,
+		stop:     make(chan struct{}),
+	}
+
+	// The address is checked once it is resolved, right before connecting, so that a
+	// host name can't point somewhere else between a check and the request. Proxies
+	// are left out, they would be the address checked.
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: s.checkAddress,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	s.client = &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// A redirect is recorded as the answer, the URL of the webhook is the one to fix
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return s
+}
+
+// checkAddress refuses the connections to the addresses of the server itself and of its
+// network, so that webhooks can't be used to reach the services behind it: loopback,
+// private, link-local, multicast and unspecified ones.
+func (s *webhookSender) checkAddress(network, address string, c syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return errPrivateAddress
+	}
+
+	return nil
+}
+
+// Stop makes the deliveries that wait for a retry give up, so that they don't hold up
+// the shutdown. They can be sent again from the delivery log.
+func (s *webhookSender) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// wait sleeps before the retry that follows the given attempt, and reports false if
+// the sender was stopped in the meantime.
+func (s *webhookSender) wait(attempt int) bool {
+	timer := time.NewTimer(s.backoff << (attempt - 1))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// send posts the payload of a delivery to the URL of the webhook once, and returns the
+// status code of the response.
+func (s *webhookSender) send(webhook *models.Webhook, deliveryID int, event string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "snippetbox-webhooks")
+	req.Header.Set("X-Snippetbox-Event", event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.Itoa(deliveryID))
+	req.Header.Set("X-Snippetbox-Signature-256", signPayload(webhook.Secret, payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read a little of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
+
+// signPayload returns the signature header of a payload: "sha256=" and the hex of its
+// HMAC-SHA256 with the secret of the webhook, which receivers compute to check it.
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether an attempt is worth making again: when there was no
+// response, a server error or a 429. Any other answer would come back the same.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+// webhookPayload is the JSON body of a delivery.
+type webhookPayload struct {
+	Event   string    `json:"event"`
+	Created time.Time `json:"created"`
+	// The snippet as it is after the event, or as it was before snippet.deleted
+	Snippet *snippetJSON `json:"snippet"`
+	// The address of the snippet page
+	URL string `json:"url"`
+	// Who caused the event, without their email address
+	Actor *webhookActorJSON `json:"actor"`
+}
+
+type webhookActorJSON struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newWebhookPayload starts the payload of event with what only the request knows.
+func (app *application) newWebhookPayload(r *http.Request, event string, user *models.User) *webhookPayload {
+	p := &webhookPayload{
+		Event:   event,
+		Created: time.Now().UTC(),
+		URL:     app.baseURL(r),
+	}
+
+	if user != nil {
+		p.Actor = &webhookActorJSON{ID: user.ID, Name: user.Name}
+	}
+
+	return p
+}
+
+// snippetEvent sends event about the snippet with the given id to the webhooks that
+// subscribe to it. Only the request is read before returning, the snippet is read in
+// the background with the rest.
+func (app *application) snippetEvent(r *http.Request, event string, id int) {
+	payload := app.newWebhookPayload(r, event, app.authenticatedUser(r))
+
+	app.background(func() {
+		snippet, err := app.snippets.Get(context.Background(), id)
+		if err != nil {
+			app.logger.Error("reading the snippet of a webhook event", "event", event, "snippet", id, "error", err.Error())
+			return
+		}
+
+		app.sendSnippetEvent(payload, snippet)
+	})
+}
+
+// snippetDeletedEvent is snippetEvent for a deleted snippet, which it is given as it was.
+func (app *application) snippetDeletedEvent(r *http.Request, snippet *models.Snippet) {
+	payload := app.newWebhookPayload(r, models.EventSnippetDeleted, app.authenticatedUser(r))
+
+	app.background(func() {
+		app.sendSnippetEvent(payload, snippet)
+	})
+}
+
+// sendSnippetEvent records a delivery of the payload to every webhook that subscribes
+// to its event about the snippet, and makes each one in its own goroutine.
+func (app *application) sendSnippetEvent(payload *webhookPayload, snippet *models.Snippet) {
+	ctx := context.Background()
+
+	webhooks, err := app.webhooks.Subscribers(ctx, snippet.UserID, snippet.OrgID, payload.Event)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload.Snippet = newSnippetJSON(snippet)
+	payload.URL += "/s/" + snippet.Slug
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, webhook := range webhooks {
+		deliveryID, err := app.webhooks.InsertDelivery(ctx, webhook.ID, payload.Event, string(body))
+		if err != nil {
+			// A webhook deleted since it was read has nothing to record
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.logger.Error(err.Error())
+			}
+			continue
+		}
+
+		app.background(func() {
+			app.deliver(webhook, deliveryID, payload.Event, body)
+		})
+	}
+}
+
+// deliver makes the attempts at a delivery until one gets an answer that isn't worth
+// retrying, with a wait that doubles between them, and records each one.
+func (app *application) deliver(webhook *models.Webhook, deliveryID int, event string, payload []byte) {
+	for attempt := 1; ; attempt++ {
+		statusCode, err := app.webhookSender.send(webhook, deliveryID, event, payload)
+
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+
+		// The address won't become public by waiting
+		refused := errors.Is(err, errPrivateAddress)
+
+		err = app.webhooks.RecordAttempt(context.Background(), deliveryID, statusCode, errMsg)
+		if err != nil {
+			// The webhook was deleted along with its deliveries
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.logger.Error(err.Error())
+			}
+			return
+		}
+
+		if refused || !retryable(statusCode) {
+			return
+		}
+
+		if attempt == app.webhookSender.attempts || !app.webhookSender.wait(attempt) {
+			app.logger.Warn("webhook delivery failed", "webhook", webhook.ID, "delivery", deliveryID,
+				"attempts", attempt, "status", statusCode, "error", errMsg)
+			return
+		}
+	}
+}
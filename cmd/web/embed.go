@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// The size of the embed frame, unless the oEmbed consumer asks for a smaller one
+const (
+	embedWidth  = 600
+	embedHeight = 400
+)
+
+// embedCode returns the HTML that shows a snippet in a frame on another site, the one
+// offered on the view page.
+func embedCode(baseURL string, s *models.Snippet) string {
+	return embedFrame(baseURL, s, embedWidth, embedHeight)
+}
+
+func embedFrame(baseURL string, s *models.Snippet, width, height int) string {
+	return fmt.Sprintf(`<iframe src="%s/s/%s/embed" width="%d" height="%d" title="%s" style="border: 0" loading="lazy"></iframe>`,
+		template.HTMLEscapeString(baseURL), s.Slug, width, height, template.HTMLEscapeString(s.Title))
+}
+
+// snippetEmbed shows a snippet alone, for a frame on another site. It goes without a
+// session, so a hidden snippet isn't shown here even to its owner.
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := &templateData{
+		CurrentYear: time.Now().Year(),
+		BaseURL:     app.baseURL(r),
+		Snippet:     snippet,
+	}
+
+	app.renderLayout(w, r, http.StatusOK, "frame", "embed.tmpl", data)
+}
+
+// oembedJSON is an oEmbed response of the rich type, https://oembed.com/#section2.3.
+type oembedJSON struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// The paths of the snippet addresses that oEmbed consumers may ask about, and of the
+// numeric ones used before the slugs
+var (
+	embedURLRX       = regexp.MustCompile(`^/s/([A-Za-z0-9_-]+)(/embed)?$`)
+	legacyEmbedURLRX = regexp.MustCompile(`^/snippet/(view|embed)/([0-9]+)$`)
+)
+
+// oembed answers the oEmbed requests for the snippets of this site with the embed frame.
+// Only the JSON format is implemented, consumers asking for XML get a 501 as the spec allows.
+func (app *application) oembed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, r, http.StatusNotImplemented)
+		return
+	}
+
+	width, height := embedWidth, embedHeight
+
+	for _, limit := range []struct {
+		name  string
+		value *int
+	}{{"maxwidth", &width}, {"maxheight", &height}} {
+		if v := query.Get(limit.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				app.clientError(w, r, http.StatusBadRequest)
+				return
+			}
+
+			*limit.value = min(*limit.value, n)
+		}
+	}
+
+	rawURL := query.Get("url")
+	if rawURL == "" {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	base := app.baseURL(r)
+
+	site, err := url.Parse(base)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Addresses on other sites, or of pages other than a snippet, have no embed
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != site.Host {
+		app.notFound(w, r)
+		return
+	}
+
+	var snippet *models.Snippet
+
+	if matches := embedURLRX.FindStringSubmatch(u.Path); matches != nil {
+		snippet, err = app.snippets.GetBySlug(r.Context(), matches[1])
+	} else if matches := legacyEmbedURLRX.FindStringSubmatch(u.Path); matches != nil {
+		id, _ := strconv.Atoi(matches[2])
+		snippet, err = app.snippets.GetLegacy(r.Context(), id)
+	} else {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Hidden {
+		app.notFound(w, r)
+		return
+	}
+
+	resp := oembedJSON{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        snippet.Title,
+		ProviderName: "Snippetbox",
+		ProviderURL:  base + "/",
+		// Consumers may keep the frame until the snippet expires, and no longer
+		CacheAge: max(0, int(time.Until(snippet.Expires).Seconds())),
+		HTML:     embedFrame(base, snippet, width, height),
+		Width:    width,
+		Height:   height,
+	}
+
+	user, err := app.users.Get(r.Context(), snippet.UserID)
+	if err == nil {
+		resp.AuthorName = user.Name
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The responses are public, so scripts on any site may read them
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestSnippetEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid slug",
+			urlPath:  "/s/pond-Xq7/embed",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/s/missing1/embed",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Hidden snippet",
+			urlPath:  "/s/frog_K2m/embed",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			// Only this site may frame the embeds when no other origin is configured
+			assert.Equal(t, headers.Get("X-Frame-Options"), "")
+			assert.StringContains(t, headers.Get("Content-Security-Policy"), "; frame-ancestors 'self'")
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				assert.StringContains(t, body, "<base target='_blank'>")
+			}
+		})
+	}
+
+	// Everything else still refuses to be framed
+	_, headers, body := ts.get(t, "/s/pond-Xq7")
+	assert.Equal(t, headers.Get("X-Frame-Options"), "deny")
+	assert.StringContains(t, body, "&lt;iframe src=&#34;https://127.0.0.1:")
+	assert.StringContains(t, body, "/s/pond-Xq7/embed&#34;")
+	assert.StringContains(t, body, "application/json+oembed")
+
+	// The numeric addresses used before the slugs redirect
+	code, headers, _ := ts.get(t, "/snippet/embed/1")
+	assert.Equal(t, code, http.StatusMovedPermanently)
+	assert.Equal(t, headers.Get("Location"), "/s/pond-Xq7/embed")
+
+	code, _, _ = ts.get(t, "/snippet/embed/3")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetEmbedFrameAncestors(t *testing.T) {
+	app := newTestApplication(t)
+	app.frameAncestors = []string{"https://wiki.example.com", "https://*.example.org"}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, headers, _ := ts.get(t, "/s/pond-Xq7/embed")
+	assert.StringContains(t, headers.Get("Content-Security-Policy"), "; frame-ancestors https://wiki.example.com https://*.example.org")
+}
+
+func TestOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "View URL",
+			query:      "url=" + url.QueryEscape(ts.URL+"/s/pond-Xq7"),
+			wantCode:   http.StatusOK,
+			wantWidth:  600,
+			wantHeight: 400,
+		},
+		{
+			name:       "Embed URL with limits",
+			query:      "format=json&maxwidth=300&maxheight=1000&url=" + url.QueryEscape(ts.URL+"/s/pond-Xq7/embed"),
+			wantCode:   http.StatusOK,
+			wantWidth:  300,
+			wantHeight: 400,
+		},
+		{
+			name:       "Numeric URL",
+			query:      "url=" + url.QueryEscape(ts.URL+"/snippet/view/1"),
+			wantCode:   http.StatusOK,
+			wantWidth:  600,
+			wantHeight: 400,
+		},
+		{
+			name:     "Other site",
+			query:    "url=" + url.QueryEscape("https://example.com/snippet/view/1"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Other page",
+			query:    "url=" + url.QueryEscape(ts.URL+"/about"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Hidden snippet",
+			query:    "url=" + url.QueryEscape(ts.URL+"/s/frog_K2m"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Missing URL",
+			query:    "format=json",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid max width",
+			query:    "maxwidth=wide&url=" + url.QueryEscape(ts.URL+"/snippet/view/1"),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "XML",
+			query:    "format=xml&url=" + url.QueryEscape(ts.URL+"/snippet/view/1"),
+			wantCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, "/oembed?"+tt.query)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+			var resp oembedJSON
+			err := json.Unmarshal([]byte(body), &resp)
+			assert.NilError(t, err)
+
+			assert.Equal(t, resp.Version, "1.0")
+			assert.Equal(t, resp.Type, "rich")
+			assert.Equal(t, resp.Title, "An old silent pond")
+			assert.Equal(t, resp.AuthorName, "Alice")
+			assert.Equal(t, resp.Width, tt.wantWidth)
+			assert.Equal(t, resp.Height, tt.wantHeight)
+			assert.StringContains(t, resp.HTML, `<iframe src="`+ts.URL+`/s/pond-Xq7/embed"`)
+		})
+	}
+}
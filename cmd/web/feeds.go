@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+const (
+	// How long a feed is served from memory before the snippets are read again. A reader
+	// that polls less often finds it expired and triggers a query, but still gets a 304
+	// if the rebuilt feed has the same ETag.
+	feedTTL = time.Minute
+	// How many feeds the cache holds, the least recently used ones are dropped first.
+	// Without a base URL every Host header gets feeds of its own, this keeps them bounded.
+	feedCacheSize = 1000
+	// Number of snippets in the feed of a user
+	feedUserLimit = 20
+)
+
+// feed is a feed ready to be served in one format.
+type feed struct {
+	key         string
+	contentType string
+	body        []byte
+	etag        string
+	modified    time.Time
+	expires     time.Time
+}
+
+// feedCache keeps the feeds built in the last feedTTL, by format and source. It also
+// remembers when each feed last changed, for Last-Modified, after its body expires,
+// until it is one of the least recently used once the cache is full.
+type feedCache struct {
+	mu   sync.Mutex
+	size int
+	// The most recently used feed is at the front
+	order *list.List
+	feeds map[string]*list.Element
+}
+
+func newFeedCache() *feedCache {
+	return &feedCache{
+		size:  feedCacheSize,
+		order: list.New(),
+		feeds: map[string]*list.Element{},
+	}
+}
+
+// get returns the feed stored under key if it is still fresh.
+func (fc *feedCache) get(key string) (*feed, bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	el, ok := fc.feeds[key]
+	if !ok {
+		return nil, false
+	}
+
+	fc.order.MoveToFront(el)
+
+	f := el.Value.(*feed)
+	if !time.Now().Before(f.expires) {
+		return nil, false
+	}
+
+	return f, true
+}
+
+// put stores f under key. If the body is the same as the last time, the modification
+// time of the last time is kept. A new body read after a restart is dated by its
+// newest entry, which is what modified already holds.
+func (fc *feedCache) put(key string, f *feed) *feed {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	now := time.Now()
+
+	f.key = key
+	f.expires = now.Add(feedTTL)
+
+	if el, ok := fc.feeds[key]; ok {
+		old := el.Value.(*feed)
+		if old.etag == f.etag {
+			f.modified = old.modified
+		} else {
+			f.modified = now
+		}
+
+		el.Value = f
+		fc.order.MoveToFront(el)
+
+		return f
+	}
+
+	fc.feeds[key] = fc.order.PushFront(f)
+
+	for fc.order.Len() > fc.size {
+		oldest := fc.order.Back()
+		fc.order.Remove(oldest)
+		delete(fc.feeds, oldest.Value.(*feed).key)
+	}
+
+	return f
+}
+
+// feedInfo describes a feed independently of its format.
+type feedInfo struct {
+	Title   string
+	Author  string
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []feedEntry
+}
+
+type feedEntry struct {
+	ID        string
+	Title     string
+	Link      string
+	Published time.Time
+	Updated   time.Time
+	Content   template.HTML
+}
+
+// newFeedInfo describes a feed of snippets. An entry is updated when its snippet was last
+// edited, and the feed when the most recently edited of its entries was.
+func newFeedInfo(base, title, author, path string, snippets []*models.Snippet) (*feedInfo, error) {
+	site, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &feedInfo{
+		Title:  title,
+		Author: author,
+		Link:   base + "/",
+		Self:   base + path,
+	}
+
+	for _, s := range snippets {
+		content, err := renderContent(s)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Entries = append(info.Entries, feedEntry{
+			ID:        snippetTagURI(site.Host, s),
+			Title:     s.Title,
+			Link:      base + "/s/" + s.Slug,
+			Published: s.Created.UTC(),
+			Updated:   s.Updated.UTC(),
+			Content:   content,
+		})
+
+		if s.Updated.After(info.Updated) {
+			info.Updated = s.Updated.UTC()
+		}
+	}
+
+	// An empty feed still needs a date, the epoch keeps it the same between builds
+	if info.Updated.IsZero() {
+		info.Updated = time.Unix(0, 0).UTC()
+	}
+
+	return info, nil
+}
+
+// snippetTagURI returns the ID of a snippet in the feeds, a tag URI (RFC 4151) that
+// doesn't change if the address of the snippet page does. It is built from the slug,
+// like the address, so that the feeds don't give away the numeric IDs either.
+func snippetTagURI(host string, s *models.Snippet) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return fmt.Sprintf("tag:%s,%s:snippet/%s", host, s.Created.UTC().Format(time.DateOnly), s.Slug)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (info *feedInfo) atom() ([]byte, error) {
+	f := atomFeed{
+		ID:      info.Self,
+		Title:   info.Title,
+		Updated: info.Updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: info.Author},
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: info.Self},
+			{Rel: "alternate", Type: "text/html", Href: info.Link},
+		},
+	}
+
+	for _, e := range info.Entries {
+		f.Entries = append(f.Entries, atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Published: e.Published.Format(time.RFC3339),
+			Updated:   e.Updated.Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: string(e.Content)},
+		})
+	}
+
+	return encodeXML(f)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (info *feedInfo) rss() ([]byte, error) {
+	f := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         info.Title,
+			Link:          info.Link,
+			Description:   info.Title,
+			LastBuildDate: info.Updated.Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range info.Entries {
+		f.Channel.Items = append(f.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Published.Format(time.RFC1123Z),
+			Description: string(e.Content),
+		})
+	}
+
+	return encodeXML(f)
+}
+
+func encodeXML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// serveFeed serves the feed stored under key, building it first if it isn't cached. The
+// format, Atom or RSS, is picked by the extension of the path. http.ServeContent answers
+// conditional requests with 304 Not Modified, from the ETag and the Last-Modified time.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, key string, build func() (*feedInfo, error)) {
+	// The links of a feed depend on the base URL, which may come from the request
+	key = app.baseURL(r) + " " + key + path.Ext(r.URL.Path)
+
+	f, ok := app.feeds.get(key)
+	if !ok {
+		info, err := build()
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		f = &feed{modified: info.Updated}
+
+		if strings.HasSuffix(r.URL.Path, ".rss") {
+			f.contentType = "application/rss+xml; charset=utf-8"
+			f.body, err = info.rss()
+		} else {
+			f.contentType = "application/atom+xml; charset=utf-8"
+			f.body, err = info.atom()
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		sum := sha256.Sum256(f.body)
+		f.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+
+		f = app.feeds.put(key, f)
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("ETag", f.etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(feedTTL.Seconds())))
+
+	http.ServeContent(w, r, "", f.modified, bytes.NewReader(f.body))
+}
+
+// feedLatest serves the latest snippets, the ones on the home page.
+func (app *application) feedLatest(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, "latest", func() (*feedInfo, error) {
+		snippets, err := app.snippets.Latest(r.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		return newFeedInfo(app.baseURL(r), "Snippetbox", "Snippetbox", r.URL.Path, snippets)
+	})
+}
+
+// feedUser serves the latest snippets of a user.
+func (app *application) feedUser(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	app.serveFeed(w, r, "user:"+strconv.Itoa(id), func() (*feedInfo, error) {
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets, err := app.snippets.ByUser(r.Context(), id, feedUserLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		return newFeedInfo(app.baseURL(r), "Snippets by "+user.Name, user.Name, r.URL.Path, snippets)
+	})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// countingSnippets counts the Latest queries that reach the snippet model.
+type countingSnippets struct {
+	models.SnippetModelInterface
+	latest atomic.Int32
+}
+
+func (m *countingSnippets) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	m.latest.Add(1)
+	return m.SnippetModelInterface.Latest(ctx)
+}
+
+// getFeed sends a GET request with the given conditional headers.
+func (ts *testServer) getFeed(t *testing.T, urlPath string, header http.Header) (int, http.Header, string) {
+	r, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header = header
+
+	rs, err := ts.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        []string
+	}{
+		{
+			name:            "Atom",
+			urlPath:         "/feed.atom",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantBody: []string{
+				`<feed xmlns="http://www.w3.org/2005/Atom">`,
+				"<title>An old silent pond</title>",
+				"<id>tag:127.0.0.1,",
+				":snippet/pond-Xq7</id>",
+				`<link rel="alternate" type="text/html" href="https://127.0.0.1:`,
+				`<content type="html">&lt;pre&gt;&lt;code&gt;An old silent pond...`,
+			},
+		},
+		{
+			name:            "RSS",
+			urlPath:         "/feed.rss",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/rss+xml; charset=utf-8",
+			wantBody: []string{
+				`<rss version="2.0">`,
+				"<title>An old silent pond</title>",
+				`<guid isPermaLink="false">tag:127.0.0.1,`,
+			},
+		},
+		{
+			name:            "User",
+			urlPath:         "/users/1/feed.atom",
+			wantCode:        http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantBody:        []string{"<title>Snippets by Alice</title>", "<title>An old silent pond</title>"},
+		},
+		{
+			name:     "Missing user",
+			urlPath:  "/users/99/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid user ID",
+			urlPath:  "/users/alice/feed.rss",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.getFeed(t, tt.urlPath, http.Header{})
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, headers.Get("Content-Type"), tt.wantContentType)
+				assert.Equal(t, headers.Get("ETag") != "", true)
+			}
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+}
+
+func TestFeedNotModified(t *testing.T) {
+	app := newTestApplication(t)
+	snippets := &countingSnippets{SnippetModelInterface: app.snippets}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.getFeed(t, "/feed.atom", http.Header{})
+	assert.Equal(t, code, http.StatusOK)
+
+	etag := headers.Get("ETag")
+	lastModified := headers.Get("Last-Modified")
+	assert.Equal(t, lastModified != "", true)
+
+	code, _, body := ts.getFeed(t, "/feed.atom", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, code, http.StatusNotModified)
+	assert.Equal(t, body, "")
+
+	code, _, _ = ts.getFeed(t, "/feed.atom", http.Header{"If-Modified-Since": {lastModified}})
+	assert.Equal(t, code, http.StatusNotModified)
+
+	code, _, _ = ts.getFeed(t, "/feed.atom", http.Header{"If-None-Match": {`"stale"`}})
+	assert.Equal(t, code, http.StatusOK)
+
+	// Every request after the first was served from the cached feed
+	assert.Equal(t, snippets.latest.Load(), int32(1))
+
+	// RSS is built and cached on its own
+	ts.getFeed(t, "/feed.rss", http.Header{})
+	assert.Equal(t, snippets.latest.Load(), int32(2))
+}
+
+func TestFeedCacheModified(t *testing.T) {
+	fc := newFeedCache()
+	created := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	f := fc.put("latest.atom", &feed{etag: `"a"`, modified: created})
+	assert.Equal(t, f.modified, created)
+
+	// The same body read again keeps its date
+	f = fc.put("latest.atom", &feed{etag: `"a"`, modified: created})
+	assert.Equal(t, f.modified, created)
+
+	// A changed body, like one with a deleted snippet, is newer than its entries
+	f = fc.put("latest.atom", &feed{etag: `"b"`, modified: created})
+	assert.Equal(t, f.modified.After(created), true)
+
+	_, ok := fc.get("latest.atom")
+	assert.Equal(t, ok, true)
+
+	_, ok = fc.get("latest.rss")
+	assert.Equal(t, ok, false)
+}
+
+func TestFeedCacheSize(t *testing.T) {
+	fc := newFeedCache()
+	fc.size = 2
+
+	fc.put("https://a.example.com latest.atom", &feed{etag: `"a"`})
+	fc.put("https://b.example.com latest.atom", &feed{etag: `"b"`})
+
+	// Reading a makes b the least recently used
+	_, ok := fc.get("https://a.example.com latest.atom")
+	assert.Equal(t, ok, true)
+
+	fc.put("https://c.example.com latest.atom", &feed{etag: `"c"`})
+	assert.Equal(t, len(fc.feeds), 2)
+
+	_, ok = fc.get("https://b.example.com latest.atom")
+	assert.Equal(t, ok, false)
+
+	_, ok = fc.get("https://a.example.com latest.atom")
+	assert.Equal(t, ok, true)
+
+	_, ok = fc.get("https://c.example.com latest.atom")
+	assert.Equal(t, ok, true)
+}
+
+func TestFeedHost(t *testing.T) {
+	// getFeedFor requests the latest feed with the Host header set to host.
+	getFeedFor := func(t *testing.T, ts *testServer, host string) string {
+		r, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Host = host
+
+		rs, err := ts.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(body)
+	}
+
+	t.Run("Host of the request", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		// A forged Host gets links to itself, but doesn't change the feed of everyone else
+		body := getFeedFor(t, ts, "evil.example.com")
+		assert.StringContains(t, body, `href="https://evil.example.com/s/pond-Xq7"`)
+
+		body = getFeedFor(t, ts, ts.Listener.Addr().String())
+		assert.StringContains(t, body, `href="https://`+ts.Listener.Addr().String()+`/s/pond-Xq7"`)
+	})
+
+	t.Run("Base URL", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.canonicalURL = "https://snippetbox.example.com"
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		body := getFeedFor(t, ts, "evil.example.com")
+		assert.StringContains(t, body, `href="https://snippetbox.example.com/s/pond-Xq7"`)
+		assert.StringContains(t, body, "<id>tag:snippetbox.example.com,")
+	})
+}
+
+func TestFeedUpdated(t *testing.T) {
+	created := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	edited := created.Add(48 * time.Hour)
+
+	snippets := []*models.Snippet{
+		{Slug: "pond-Xq7", Title: "An old silent pond", Content: "A frog jumps in", ContentType: models.ContentTypeText, Created: created.Add(time.Hour), Updated: created.Add(time.Hour)},
+		{Slug: "frog_K2m", Title: "A frog jumps", Content: "Into the pond", ContentType: models.ContentTypeText, Created: created, Updated: edited},
+	}
+
+	info, err := newFeedInfo("https://snippetbox.example.com", "Latest snippets", "Snippetbox", "/feed.atom", snippets)
+	assert.NilError(t, err)
+
+	// The feed is as new as its last edit, not as its newest entry
+	assert.Equal(t, info.Updated, edited)
+
+	body, err := info.atom()
+	assert.NilError(t, err)
+	assert.StringContains(t, string(body), "<updated>2024-03-19T10:15:00Z</updated>")
+	assert.StringContains(t, string(body), "<published>2024-03-17T10:15:00Z</published>")
+}
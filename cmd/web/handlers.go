@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/shtayeb/snippetbox/internal/models"
@@ -27,14 +29,14 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
 
-	app.render(w, http.StatusOK, "signup.tmpl", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	var form userSignupForm
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -48,20 +50,20 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -74,6 +76,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"rememberMe"`
 	validator.Validator `form:"-"`
 }
 
@@ -81,7 +84,7 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
 
-	app.render(w, http.StatusOK, "login.tmpl", data)
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -89,7 +92,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -102,32 +105,90 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		data := app.newTemplateData(r)
 		data.Form = form
 
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	// Check the throttle before the password. Failures are counted against the submitted
+	// email whether or not an account exists, so the response never reveals which emails are registered.
+	ip := app.clientIP(r)
+
+	failures, err := app.loginAttempts.Failures(r.Context(), form.Email, ip, time.Now().Add(-app.loginLimits.window))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if wait := app.loginLimits.retryAfter(failures); wait > 0 {
+		form.AddNonFieldError("Too many failed login attempts. Please try again later.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+
+		app.metrics.logins.WithLabelValues("throttled").Inc()
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		app.render(w, r, http.StatusTooManyRequests, "login.tmpl", data)
+		return
+	}
+
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.metrics.logins.WithLabelValues("failure").Inc()
+
+			// The failure is recorded even if the client hangs up, or guesses could be made
+			// for free by dropping the connection as soon as the password has been checked
+			err = app.recordLoginFailure(context.WithoutCancel(r.Context()), form.Email, ip, failures)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
 
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		} else if errors.Is(err, models.ErrAccountDisabled) {
+			app.metrics.logins.WithLabelValues("disabled").Inc()
+
+			form.AddNonFieldError("Your account has been disabled")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+
+			app.render(w, r, http.StatusForbidden, "login.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
+		return
+	}
+
+	err = app.loginAttempts.Reset(r.Context(), form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	// A "remember me" login keeps the long manager lifetime and gets a persistent cookie,
+	// any other login is cut down to the standard session lifetime
+	if form.RememberMe {
+		app.sessionManager.RememberMe(r.Context(), true)
+	} else {
+		app.sessionManager.SetDeadline(r.Context(), time.Now().Add(app.sessionLifetime).UTC())
+	}
+
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.metrics.logins.WithLabelValues("success").Inc()
 
 	refererUrl, _ := url.Parse(r.Referer())
 	path := refererUrl.Query().Get("next")
@@ -142,7 +203,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -156,13 +217,13 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 func (app *application) about(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
-	app.render(w, http.StatusOK, "about.tmpl", data)
+	app.render(w, r, http.StatusOK, "about.tmpl", data)
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -171,37 +232,86 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	data.Snippets = snippets
 
 	// use the render helper
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	collections, err := app.collections.BySnippet(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	// Use the SnippetModel object's Get method to retrieve the data for a
-	// specific record based on its ID. If no matching record is found,
-	// return a 404 Not Found response.
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	// Private collections are only listed to their owner
+	data.Collections = slices.DeleteFunc(collections, func(c *models.Collection) bool {
+		return !app.canViewCollection(r, c)
+	})
+
+	if user := data.AuthenticatedUser; user != nil {
+		data.OwnCollections, err = app.collections.ByUser(r.Context(), user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
-	snippet, err := app.snippets.Get(id)
-	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
+	// The organization is named on the page, unless it is gone
+	if snippet.OrgID != 0 {
+		data.Organization, err = app.organizations.Get(r.Context(), snippet.OrgID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
 		}
+	}
 
+	data.CanEdit, err = app.canEditSnippet(r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// snippetRedirect sends the numeric addresses of the snippets used before the slugs, like
+// /snippet/view/:id, to their /s/:slug address followed by suffix. Snippets made since
+// have no numeric address, so walking the IDs finds none of their slugs.
+func (app *application) snippetRedirect(suffix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		id, err := strconv.Atoi(params.ByName("id"))
+		if err != nil || id < 1 {
+			app.notFound(w, r)
+			return
+		}
+
+		snippet, err := app.snippets.GetLegacy(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
 
-	app.render(w, http.StatusOK, "view.tmpl", data)
+			return
+		}
+
+		// The slug of a hidden snippet is only given to those who may see it
+		if !app.canViewSnippet(r, snippet) {
+			app.notFound(w, r)
+			return
+		}
+
+		http.Redirect(w, r, "/s/"+snippet.Slug+suffix, http.StatusMovedPermanently)
+	})
 }
 
 // type. Embedding this means that our snippetCreateForm "inherits" all the
@@ -213,9 +323,12 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 // input with the name "title" in the Title field. The struct tag `form:"-"`
 // tells the decoder to completely ignore a field during decoding.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title       string `form:"title"`
+	Content     string `form:"content"`
+	ContentType string `form:"content_type"`
+	Expires     int    `form:"expires"`
+	// The organization the snippet is created for, zero for a snippet of one's own
+	OrgID               int `form:"org"`
 	validator.Validator `form:"-"`
 }
 
@@ -224,62 +337,232 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
+	// Forms from before markdown snippets don't send a content type
+	if form.ContentType == "" {
+		form.ContentType = models.ContentTypeText
+	}
+
 	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
 
 	form.CheckField(validator.NotBlank(form.Content), "content", "This filed cannot be blank")
+	form.CheckField(validator.PermittedValue(form.ContentType, models.ContentTypeText, models.ContentTypeMarkdown), "content_type", "This field must equal text or markdown")
 	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
 
+	if form.OrgID != 0 {
+		membership, err := app.membership(r, form.OrgID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		form.CheckField(canCreateOrgSnippet(membership), "org", "You are not a member of this organization")
+	}
+
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+
+		data.Memberships, err = app.organizations.ByUser(r.Context(), app.authenticatedUser(r).ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	id, slug, err := app.snippets.Insert(r.Context(), app.authenticatedUser(r).ID, form.OrgID, form.Title, form.Content, form.ContentType, form.Expires)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	app.metrics.snippetsCreated.Inc()
+	app.snippetEvent(r, models.EventSnippetCreated, id)
+
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/s/"+slug, http.StatusSeeOther)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		ContentType: models.ContentTypeText,
+		Expires:     365,
+	}
+
+	// The snippet can be created for any organization of the user
+	memberships, err := app.organizations.ByUser(r.Context(), app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Memberships = memberships
+
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
+}
+
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	ContentType         string `form:"content_type"`
+	validator.Validator `form:"-"`
+}
+
+// editableSnippet is viewableSnippet for the changes that only some users may make to
+// a snippet. The others get a 403.
+func (app *application) editableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	canEdit, err := app.canEditSnippet(r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	if !canEdit {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.editableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:       snippet.Title,
+		Content:     snippet.Content,
+		ContentType: snippet.ContentType,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+// snippetEditPost changes the title and content of a snippet. Its address and expiry
+// stay as they are.
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.editableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.ContentType, models.ContentTypeText, models.ContentTypeMarkdown), "content_type", "This field must equal text or markdown")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Update(r.Context(), snippet.ID, form.Title, form.Content, form.ContentType)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.snippetEvent(r, models.EventSnippetUpdated, snippet.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+type snippetPreviewForm struct {
+	Content     string `form:"content"`
+	ContentType string `form:"content_type"`
+}
+
+// snippetPreviewPost renders the content of the create form the way the snippet page
+// will show it, as an HTML fragment for the form to display.
+func (app *application) snippetPreviewPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetPreviewForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.ContentType, "", models.ContentTypeText, models.ContentTypeMarkdown) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl", data)
+	preview, err := renderContent(&models.Snippet{Content: form.Content, ContentType: form.ContentType})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(preview))
 }
 
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
 	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	user, err := app.users.Get(userID)
+	user, err := app.users.Get(r.Context(), userID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		return
 	}
 
+	collections, err := app.collections.ByUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	memberships, err := app.organizations.ByUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.User = user
+	data.Collections = collections
+	data.Memberships = memberships
 
-	app.render(w, http.StatusOK, "account.tmpl", data)
+	app.render(w, r, http.StatusOK, "account.tmpl", data)
 }
 
 type accountPasswordUpdateForm struct {
@@ -293,7 +576,7 @@ func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 	data := app.newTemplateData(r)
 	data.Form = accountPasswordUpdateForm{}
 
-	app.render(w, http.StatusOK, "password.tmpl", data)
+	app.render(w, r, http.StatusOK, "password.tmpl", data)
 }
 
 func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
@@ -301,7 +584,7 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -313,26 +596,32 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "password.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
 		return
 	}
 	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	err = app.users.PasswordUpdate(userID, form.CurrentPassword, form.NewPassword)
+	err = app.users.PasswordUpdate(r.Context(), userID, form.CurrentPassword, form.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddFieldError("currentPassword", "Current password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "password.tmpl",
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl",
 				data)
 		} else if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		return
 	}
 
+	err = app.shortenRememberedSessions(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
 
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
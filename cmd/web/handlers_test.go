@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/shtayeb/snippetbox/internal/assert"
@@ -199,17 +201,29 @@ func TestSnippetView(t *testing.T) {
 
 	// Set up some table-driven tests to check the responses sent by our application for different URLs.
 	tests := []struct {
-		name     string
-		urlPath  string
-		wantCode int
-		wantBody string
+		name         string
+		urlPath      string
+		wantCode     int
+		wantBody     string
+		wantLocation string
 	}{
 		{
-			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			name:     "Valid slug",
+			urlPath:  "/s/pond-Xq7",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/s/missing1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Valid ID",
+			urlPath:      "/snippet/view/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/pond-Xq7",
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -239,9 +253,10 @@ func TestSnippetView(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			code, _, body := ts.get(t, tt.urlPath)
+			code, headers, body := ts.get(t, tt.urlPath)
 
 			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
 
 			if tt.wantBody != "" {
 				assert.StringContains(t, body, tt.wantBody)
@@ -249,3 +264,212 @@ func TestSnippetView(t *testing.T) {
 		})
 	}
 }
+
+// slugPathRX matches the address of a snippet with a generated slug
+var slugPathRX = regexp.MustCompile(`^/s/[A-Za-z0-9_-]{8}$`)
+
+func TestSnippetCreateAndView(t *testing.T) {
+	// The memory backend keeps what the requests write, so the flow can be followed from signup to the new snippet
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	ts.login(t, "bob@example.com", "validPa$$word")
+
+	_, _, body = ts.get(t, "/snippet/create")
+
+	form = url.Values{}
+	form.Add("title", "O snail")
+	form.Add("content", "Climb Mount Fuji, but slowly, slowly!")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	location := headers.Get("Location")
+	assert.Equal(t, slugPathRX.MatchString(location), true)
+
+	code, _, body = ts.get(t, location)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet successfully created!")
+	assert.StringContains(t, body, "Climb Mount Fuji, but slowly, slowly!")
+
+	// The snippet is listed on the home page, where anyone can see it
+	code, _, body = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "O snail")
+
+	// Only the snippets made before the slugs have a numeric address
+	code, _, _ = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Markdown snippets are shown rendered and sanitized
+	form.Set("title", "Frogs")
+	form.Set("content", "# The old pond\n\nA [frog](https://example.com) jumps in.<script>alert(1)</script>")
+	form.Set("content_type", "markdown")
+
+	code, headers, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location") != location, true)
+
+	_, _, body = ts.get(t, headers.Get("Location"))
+	assert.StringContains(t, body, "<h1>The old pond</h1>")
+	assert.StringContains(t, body, `<a href="https://example.com" rel="nofollow noopener">frog</a>`)
+	assert.Equal(t, strings.Contains(body, "<script>alert"), false)
+}
+
+func TestSnippetPreview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	// Anonymous users are sent to the login page like for the create form
+	form := url.Values{}
+	form.Add("content", "*pond*")
+	form.Add("content_type", "markdown")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.StringContains(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body = ts.get(t, "/snippet/create")
+	csrfToken = extractCSRFToken(t, body)
+
+	tests := []struct {
+		name        string
+		content     string
+		contentType string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "Markdown",
+			content:     "An *old* pond<img src=x onerror=alert(1)>",
+			contentType: "markdown",
+			wantCode:    http.StatusOK,
+			wantBody:    "<p>An <em>old</em> pond</p>",
+		},
+		{
+			name:        "Text",
+			content:     "An *old* pond<b>",
+			contentType: "text",
+			wantCode:    http.StatusOK,
+			wantBody:    "<pre><code>An *old* pond&lt;b&gt;</code></pre>",
+		},
+		{
+			name:        "Unknown content type",
+			content:     "An old pond",
+			contentType: "html",
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("content_type", tt.contentType)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/preview", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.TrimSpace(body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserLoginRememberMe(t *testing.T) {
+	tests := []struct {
+		name        string
+		rememberMe  string
+		wantPersist bool
+	}{
+		{
+			name:        "Remember me ticked",
+			rememberMe:  "true",
+			wantPersist: true,
+		},
+		{
+			name:        "Remember me not ticked",
+			rememberMe:  "",
+			wantPersist: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+			csrfToken := extractCSRFToken(t, body)
+
+			form := url.Values{}
+			form.Add("email", "alice@example.com")
+			form.Add("password", "pa$$word")
+			form.Add("rememberMe", tt.rememberMe)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			// Only a persistent session cookie carries an expiry
+			var session *http.Cookie
+			for _, c := range (&http.Response{Header: headers}).Cookies() {
+				if c.Name == app.sessionManager.Cookie.Name {
+					session = c
+				}
+			}
+			if session == nil {
+				t.Fatal("no session cookie set")
+			}
+
+			assert.Equal(t, session.MaxAge > 0, tt.wantPersist)
+		})
+	}
+}
+
+func TestUserLoginLockout(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	// The mock reports enough recent failures against this account to lock it out,
+	// so even the right password must be refused
+	form := url.Values{}
+	form.Add("email", "locked@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, body := ts.postForm(t, "/user/login", form)
+
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Retry-After") != "", true)
+	assert.StringContains(t, body, "Too many failed login attempts. Please try again later.")
+}
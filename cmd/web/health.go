@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// How long each readiness check may take before it counts as failed
+const readinessTimeout = 2 * time.Second
+
+// pinger is satisfied by *sql.DB. Tests use a fake so they don't need a database.
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type healthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// healthz is the liveness probe. If the process can answer at all it is alive, the
+// dependencies are the business of readyz.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	app.writeJSON(w, http.StatusOK, healthReport{Status: "ok"})
+}
+
+// readyz is the readiness probe. It runs every check concurrently, each with its
+// own timeout, and answers 503 if any of them fails or the server is shutting down.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(ctx context.Context) error{
+		"database": func(ctx context.Context) error {
+			return app.db.PingContext(ctx)
+		},
+		"sessions": func(ctx context.Context) error {
+			return findSession(ctx, app.sessionManager.Store, "readyz-probe")
+		},
+		"templates": func(ctx context.Context) error {
+			templateCache, err := app.templates()
+			if err != nil {
+				return err
+			}
+			if len(templateCache) == 0 {
+				return errors.New("template cache is empty")
+			}
+			return nil
+		},
+		"shutdown": func(ctx context.Context) error {
+			if app.shuttingDown.Load() {
+				return errors.New("server is shutting down")
+			}
+			return nil
+		},
+	}
+
+	report := healthReport{Status: "ok", Checks: make(map[string]healthCheck, len(checks))}
+	status := http.StatusOK
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := runWithTimeout(r.Context(), readinessTimeout, check)
+
+			hc := healthCheck{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				hc.Status = "fail"
+				hc.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = hc
+			if err != nil {
+				report.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	w.Header().Set("Cache-Control", "no-store")
+	app.writeJSON(w, status, report)
+}
+
+// runWithTimeout runs check in its own goroutine so that checks which can't take a
+// context, like the session store, still give up after the timeout.
+func runWithTimeout(ctx context.Context, timeout time.Duration, check func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// findSession looks up a session token, through the context-aware method when the
+// store has one. A missing token is fine, only store errors count.
+func findSession(ctx context.Context, store scs.Store, token string) error {
+	var err error
+
+	if cs, ok := store.(scs.CtxStore); ok {
+		_, _, err = cs.FindCtx(ctx, token)
+	} else {
+		_, _, err = store.Find(token)
+	}
+
+	return err
+}
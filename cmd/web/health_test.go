@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	assert.StringContains(t, body, `"status":"ok"`)
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name         string
+		dbErr        error
+		shuttingDown bool
+		wantCode     int
+		wantFailed   string
+	}{
+		{
+			name:     "Ready",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Database down",
+			dbErr:      errors.New("connection refused"),
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: "database",
+		},
+		{
+			name:         "Shutting down",
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+			wantFailed:   "shutdown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.db = &fakeDB{err: tt.dbErr}
+			app.shuttingDown.Store(tt.shuttingDown)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+
+			assert.Equal(t, code, tt.wantCode)
+
+			var report healthReport
+			err := json.Unmarshal([]byte(body), &report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every check is reported, whether it passed or not
+			for _, name := range []string{"database", "sessions", "templates", "shutdown"} {
+				want := "ok"
+				if name == tt.wantFailed {
+					want = "fail"
+				}
+
+				assert.Equal(t, report.Checks[name].Status, want)
+			}
+		})
+	}
+}
@@ -2,16 +2,147 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"net"
 	"net/http"
+	"net/netip"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"github.com/shtayeb/snippetbox/internal/models"
 )
 
+// statusClientClosedRequest is the non-standard status that nginx logs for a request
+// whose client closed the connection before the response was sent.
+const statusClientClosedRequest = 499
+
+// loginLimits is the policy used to throttle password guessing against POST /user/login.
+type loginLimits struct {
+	// Failed attempts older than window are forgotten
+	window time.Duration
+	// Failures against an account before progressive delays kick in
+	freeAttempts int
+	// First delay, doubled for every further failure up to maxDelay
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	// Failures against one account, or from one IP address, that lock out further attempts
+	accountLockout  int
+	ipLockout       int
+	lockoutDuration time.Duration
+}
+
+var defaultLoginLimits = loginLimits{
+	window:          time.Hour,
+	freeAttempts:    3,
+	baseDelay:       time.Second,
+	maxDelay:        time.Minute,
+	accountLockout:  10,
+	ipLockout:       50,
+	lockoutDuration: 15 * time.Minute,
+}
+
+// locked reports whether the failure counts have reached a lockout threshold.
+func (l loginLimits) locked(f *models.LoginFailures) bool {
+	return f.Email >= l.accountLockout || f.IP >= l.ipLockout
+}
+
+// retryAfter returns how long a client has to wait before its next login attempt
+// is checked, or zero if it may try straight away. The account and the IP address
+// each call for their own delay, counted from their own latest failure, so that
+// failures from an address against other accounts don't hold up this one.
+func (l loginLimits) retryAfter(f *models.LoginFailures) time.Duration {
+	var emailWait, ipWait time.Duration
+
+	switch {
+	case f.Email >= l.accountLockout:
+		emailWait = l.lockoutDuration
+	case f.Email > l.freeAttempts:
+		emailWait = l.baseDelay
+		for i := l.freeAttempts + 1; i < f.Email && emailWait < l.maxDelay; i++ {
+			emailWait *= 2
+		}
+		emailWait = min(emailWait, l.maxDelay)
+	}
+
+	if f.IP >= l.ipLockout {
+		ipWait = l.lockoutDuration
+	}
+
+	return max(emailWait-time.Since(f.EmailLast), ipWait-time.Since(f.IPLast), 0)
+}
+
+// recordLoginFailure stores a failed login attempt and, if it pushes the account or the
+// IP address over a lockout threshold, writes an audit record for the lockout.
+func (app *application) recordLoginFailure(ctx context.Context, email, ip string, failures *models.LoginFailures) error {
+	err := app.loginAttempts.RecordFailure(ctx, email, ip)
+	if err != nil {
+		return err
+	}
+
+	f := &models.LoginFailures{Email: failures.Email + 1, IP: failures.IP + 1}
+	if !app.loginLimits.locked(f) {
+		return nil
+	}
+
+	until := time.Now().Add(app.loginLimits.lockoutDuration)
+	app.logger.Warn("login locked out", "email", email, "ip", ip, "until", until)
+
+	return app.loginAttempts.RecordLockout(ctx, email, ip, max(f.Email, f.IP), until)
+}
+
+// clientIP returns the IP address of the client that made the request. The
+// X-Forwarded-For header is only believed when the request comes from a trusted
+// proxy, and then only up to the first address that isn't a trusted proxy itself,
+// since anything to the left of that can be made up by the client.
+func (app *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !app.isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+
+		host = hop
+		if !app.isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func (app *application) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
 	if !ok {
@@ -21,6 +152,107 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
+// baseURL returns the scheme and host of the links to the site: the configured
+// base URL, else the host the request was made to over HTTPS. The Host header comes
+// from the client, so nothing built from it may be shared with other clients.
+func (app *application) baseURL(r *http.Request) string {
+	if app.canonicalURL != "" {
+		return app.canonicalURL
+	}
+
+	return "https://" + r.Host
+}
+
+// requestID returns the ID that the requestID middleware gave the request.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// authenticatedUser returns the logged in user, or nil for anonymous requests.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(*models.User)
+	if !ok {
+		return nil
+	}
+
+	return user
+}
+
+// shortenRememberedSessions caps the deadline of every session belonging to the user at the
+// standard session lifetime and drops their "remember me" flag. It is called after a password
+// change so that long-lived sessions on other devices don't outlive the old password for weeks.
+func (app *application) shortenRememberedSessions(ctx context.Context, userID int) error {
+	deadline := time.Now().Add(app.sessionLifetime).UTC()
+
+	shorten := func(ctx context.Context) {
+		app.sessionManager.RememberMe(ctx, false)
+		if app.sessionManager.Deadline(ctx).After(deadline) {
+			app.sessionManager.SetDeadline(ctx, deadline)
+		}
+	}
+
+	err := app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+
+		shorten(ctx)
+
+		_, _, err := app.sessionManager.Commit(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// The current session is committed again by LoadAndSave at the end of the request,
+	// so it has to be shortened on the request context too
+	shorten(ctx)
+
+	return nil
+}
+
+// endSessions logs the user out of every session they have, after an admin has
+// replaced their password.
+func (app *application) endSessions(ctx context.Context, userID int) error {
+	return app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+
+		app.sessionManager.Remove(ctx, "authenticatedUserID")
+		app.sessionManager.RememberMe(ctx, false)
+
+		_, _, err := app.sessionManager.Commit(ctx)
+		return err
+	})
+}
+
+// viewableSnippet loads the snippet named by the :slug parameter of the route, writing a
+// 404 if it doesn't exist or the current user isn't allowed to see it.
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.snippets.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	if !app.canViewSnippet(r, snippet) {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
 func (app *application) decodePostForm(r *http.Request, dst any) error {
 	// Call ParseForm() on the request
 	err := r.ParseForm()
@@ -48,21 +280,54 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:       time.Now().Year(),
+		BaseURL:           app.baseURL(r),
+		Flash:             app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:   app.isAuthenticated(r),
+		AuthenticatedUser: app.authenticatedUser(r),
+		CSRFToken:         nosurf.Token(r),
+	}
+
+}
+
+// templates returns the template cache, reparsed from disk first if it is reloaded.
+func (app *application) templates() (map[string]*template.Template, error) {
+	if app.templateReloader != nil {
+		return app.templateReloader.templates()
 	}
 
+	return app.templateCache, nil
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
+	app.renderLayout(w, r, status, "base", page, data)
+}
+
+// renderLayout renders a page in the given layout, base for the pages of the site and
+// frame for the ones shown on other sites.
+func (app *application) renderLayout(w http.ResponseWriter, r *http.Request, status int, layout string, page string, data *templateData) {
+	// The page depends on the Accept header, caches must keep the two apart
+	w.Header().Add("Vary", "Accept")
+
+	if wantsJSON(r) {
+		app.writeJSON(w, status, newPageJSON(page, data))
+		return
+	}
+
+	// In debug mode a template that doesn't parse ends up here, and serverError shows
+	// the parse error in the browser
+	templateCache, err := app.templates()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Retrieve the appropriate template set from the cache based on the page name (like 'home.tmpl')
 	// If no entry exists in the cache with the provided name, then create a new error and call the serverError() helper
-	ts, ok := app.templateCache[page]
+	ts, ok := templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -70,9 +335,11 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	buf := new(bytes.Buffer)
 
 	// write the template to the buffer, instead of straight to the ResponseWrite, if there is an error then return serverError()
-	err := ts.ExecuteTemplate(buf, "base", data)
+	start := time.Now()
+	err = ts.ExecuteTemplate(buf, layout, data)
+	app.metrics.renderDuration.WithLabelValues(page).Observe(time.Since(start).Seconds())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -84,30 +351,74 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	buf.WriteTo(w)
 }
 
-// The serverError helper writes an error message and stack trace to the errorLog,
-// then sends a generic 500 Internal Server Error response to the user.
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+// writeJSON sends v as a JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, v any) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		// Only a programming error can get here, so there is no request to report it against
+		app.logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// The serverError helper writes an error message and stack trace to the log,
+// then sends a generic 500 Internal Server Error response to the user. The response
+// carries the request ID so that a user's report can be matched to the log entry.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	id := requestID(r)
+
+	// A query abandoned because the client went away isn't a failure of the server. Nobody
+	// reads the response, the status is only there for the request log and the metrics.
+	if errors.Is(err, models.ErrCanceled) || errors.Is(err, context.Canceled) {
+		app.logger.Debug("request canceled", "error", err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", id)
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+
+	trace := string(debug.Stack())
+
+	app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", id, "trace", trace)
+
+	if wantsJSON(r) {
+		message := http.StatusText(http.StatusInternalServerError)
+		if app.debug {
+			message = err.Error()
+		}
+
+		app.writeJSON(w, http.StatusInternalServerError, errorJSON{Error: message, RequestID: id})
+		return
+	}
 
 	if app.debug {
-		http.Error(w, trace, http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("%s\nRequest ID: %s\n\n%s", err.Error(), id, trace), http.StatusInternalServerError)
 		return
 	}
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	http.Error(w, fmt.Sprintf("%s\nRequest ID: %s", http.StatusText(http.StatusInternalServerError), id), http.StatusInternalServerError)
 }
 
 // The clientError helper sends a specific status code and corresponding description
 // to the user. We'll use this later in the book to send responses like 400 "Bad
 // Request" when there's a problem with the request that the user sent.
-func (app *application) clientError(w http.ResponseWriter, status int) {
+// Clients that prefer JSON get the description as a JSON object.
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	if wantsJSON(r) {
+		app.writeJSON(w, status, errorJSON{Error: http.StatusText(status)})
+		return
+	}
+
 	http.Error(w, http.StatusText(status), status)
 }
 
 // For consistency, we'll also implement a notFound helper. This is simply a
 // convenience wrapper around clientError which sends a 404 Not Found response to
 // the user.
-func (app *application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+func TestServerError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantLogged bool
+	}{
+		{
+			name:       "Failure",
+			err:        errors.New("pq: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantLogged: true,
+		},
+		{
+			name:       "Canceled query",
+			err:        fmt.Errorf("%w: pq: canceling statement due to user request", models.ErrCanceled),
+			wantStatus: statusClientClosedRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/s/pond-Xq7", nil)
+
+			app.serverError(rr, r, tt.err)
+
+			assert.Equal(t, rr.Code, tt.wantStatus)
+			assert.Equal(t, bytes.Contains(logs.Bytes(), []byte("level=ERROR")), tt.wantLogged)
+		})
+	}
+}
+
+func TestLoginRetryAfter(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		failures models.LoginFailures
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{
+			name:     "No failures",
+			failures: models.LoginFailures{},
+		},
+		{
+			name:     "Free attempts",
+			failures: models.LoginFailures{Email: 3, EmailLast: now},
+		},
+		{
+			name:     "Progressive delay",
+			failures: models.LoginFailures{Email: 5, EmailLast: now},
+			wantMin:  time.Second,
+			wantMax:  2 * time.Second,
+		},
+		{
+			name:     "Account locked out",
+			failures: models.LoginFailures{Email: 10, EmailLast: now},
+			wantMin:  14 * time.Minute,
+			wantMax:  15 * time.Minute,
+		},
+		{
+			// A later failure from the address against another account doesn't
+			// restart the delay of this one
+			name:     "Delay of the account from its own failure",
+			failures: models.LoginFailures{Email: 5, EmailLast: hourAgo, IP: 20, IPLast: now},
+		},
+		{
+			name:     "Address locked out",
+			failures: models.LoginFailures{Email: 1, EmailLast: hourAgo, IP: 50, IPLast: now},
+			wantMin:  14 * time.Minute,
+			wantMax:  15 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait := defaultLoginLimits.retryAfter(&tt.failures)
+
+			assert.Equal(t, wait >= tt.wantMin, true)
+			assert.Equal(t, wait <= tt.wantMax, true)
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// wantsJSON reports whether the Accept header of the request prefers application/json
+// to HTML. A wildcard only counts for HTML, which is what a client gets by default, so
+// "application/json;q=0.5, */*" still gets the page.
+func wantsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ, anyQ float64
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(v, 64)
+				if err != nil {
+					continue
+				}
+			}
+
+			switch mediaType {
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			case "text/html", "text/*":
+				htmlQ = max(htmlQ, q)
+			case "*/*":
+				anyQ = max(anyQ, q)
+			}
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ && jsonQ >= anyQ
+}
+
+// pageJSON is the JSON form of templateData. Every page has every key, with null or
+// false for what it doesn't show, so that scripts can rely on the shape. Secrets like
+// the CSRF token and the password hashes are left out.
+type pageJSON struct {
+	Page              string                  `json:"page"`
+	Flash             string                  `json:"flash"`
+	IsAuthenticated   bool                    `json:"is_authenticated"`
+	AuthenticatedUser *userJSON               `json:"authenticated_user"`
+	Snippet           *snippetJSON            `json:"snippet"`
+	Snippets          []*snippetJSON          `json:"snippets"`
+	User              *userJSON               `json:"user"`
+	Users             []*userJSON             `json:"users"`
+	Pagination        *paginationJSON         `json:"pagination"`
+	Reports           []*reportJSON           `json:"reports"`
+	ModerationLog     []*moderationActionJSON `json:"moderation_log"`
+	Collection        *collectionJSON         `json:"collection"`
+	Collections       []*collectionJSON       `json:"collections"`
+	CanEdit           bool                    `json:"can_edit"`
+	Organization      *organizationJSON       `json:"organization"`
+	Membership        *membershipJSON         `json:"membership"`
+	Members           []*membershipJSON       `json:"members"`
+	Memberships       []*membershipJSON       `json:"memberships"`
+	Invitation        *invitationJSON         `json:"invitation"`
+	Invitations       []*invitationJSON       `json:"invitations"`
+	InvitationURL     string                  `json:"invitation_url"`
+	TemporaryPassword string                  `json:"temporary_password"`
+	Webhook           *webhookJSON            `json:"webhook"`
+	Webhooks          []*webhookJSON          `json:"webhooks"`
+	Deliveries        []*deliveryJSON         `json:"deliveries"`
+	Errors            *formErrorsJSON         `json:"errors"`
+}
+
+// snippetJSON leaves out the ID, which would tell how many snippets there are.
+type snippetJSON struct {
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	ContentType string    `json:"content_type"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+	UserID      int       `json:"user_id"`
+	Hidden      bool      `json:"hidden"`
+	OrgID       int       `json:"org_id"`
+}
+
+type userJSON struct {
+	ID                    int       `json:"id"`
+	Name                  string    `json:"name"`
+	Email                 string    `json:"email"`
+	Created               time.Time `json:"created"`
+	Role                  string    `json:"role"`
+	Disabled              bool      `json:"disabled"`
+	PasswordResetRequired bool      `json:"password_reset_required"`
+}
+
+type paginationJSON struct {
+	Query    string `json:"query"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+	Total    int    `json:"total"`
+	LastPage int    `json:"last_page"`
+}
+
+type reportJSON struct {
+	SnippetID    int       `json:"snippet_id"`
+	Slug         string    `json:"slug"`
+	Title        string    `json:"title"`
+	Hidden       bool      `json:"hidden"`
+	Reasons      []string  `json:"reasons"`
+	LastReported time.Time `json:"last_reported"`
+}
+
+type moderationActionJSON struct {
+	ID            int       `json:"id"`
+	SnippetID     int       `json:"snippet_id"`
+	ModeratorName string    `json:"moderator_name"`
+	Action        string    `json:"action"`
+	Created       time.Time `json:"created"`
+}
+
+type collectionJSON struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	Created     time.Time `json:"created"`
+}
+
+type organizationJSON struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// membershipJSON leaves the email address of the member out, the pages don't show it.
+type membershipJSON struct {
+	OrgID    int       `json:"org_id"`
+	OrgName  string    `json:"org_name"`
+	UserID   int       `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Role     string    `json:"role"`
+	Joined   time.Time `json:"joined"`
+}
+
+type invitationJSON struct {
+	ID      int       `json:"id"`
+	OrgID   int       `json:"org_id"`
+	OrgName string    `json:"org_name"`
+	Email   string    `json:"email"`
+	Role    string    `json:"role"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// webhookJSON leaves the secret out, only the page shows it.
+type webhookJSON struct {
+	ID      int       `json:"id"`
+	UserID  int       `json:"user_id"`
+	OrgID   int       `json:"org_id"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Created time.Time `json:"created"`
+}
+
+type deliveryJSON struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	Event      string    `json:"event"`
+	Payload    string    `json:"payload"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+// formErrorsJSON holds the validation errors of a form that was sent back.
+type formErrorsJSON struct {
+	Fields map[string]string `json:"fields"`
+	Form   []string          `json:"form"`
+}
+
+// errorJSON is the body of the error responses sent to JSON clients.
+type errorJSON struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func newSnippetJSON(s *models.Snippet) *snippetJSON {
+	if s == nil {
+		return nil
+	}
+
+	return &snippetJSON{
+		Slug:        s.Slug,
+		Title:       s.Title,
+		Content:     s.Content,
+		ContentType: s.ContentType,
+		Created:     s.Created,
+		Expires:     s.Expires,
+		UserID:      s.UserID,
+		Hidden:      s.Hidden,
+		OrgID:       s.OrgID,
+	}
+}
+
+func newUserJSON(u *models.User) *userJSON {
+	if u == nil {
+		return nil
+	}
+
+	return &userJSON{
+		ID:                    u.ID,
+		Name:                  u.Name,
+		Email:                 u.Email,
+		Created:               u.Created,
+		Role:                  u.Role,
+		Disabled:              u.Disabled,
+		PasswordResetRequired: u.PasswordResetRequired,
+	}
+}
+
+func newCollectionJSON(c *models.Collection) *collectionJSON {
+	if c == nil {
+		return nil
+	}
+
+	return &collectionJSON{
+		ID:          c.ID,
+		UserID:      c.UserID,
+		Title:       c.Title,
+		Description: c.Description,
+		Visibility:  c.Visibility,
+		Created:     c.Created,
+	}
+}
+
+func newOrganizationJSON(o *models.Organization) *organizationJSON {
+	if o == nil {
+		return nil
+	}
+
+	return &organizationJSON{ID: o.ID, Name: o.Name, Created: o.Created}
+}
+
+func newMembershipJSON(m *models.Membership) *membershipJSON {
+	if m == nil {
+		return nil
+	}
+
+	return &membershipJSON{
+		OrgID:    m.OrgID,
+		OrgName:  m.OrgName,
+		UserID:   m.UserID,
+		UserName: m.UserName,
+		Role:     m.Role,
+		Joined:   m.Joined,
+	}
+}
+
+func newInvitationJSON(i *models.Invitation) *invitationJSON {
+	if i == nil {
+		return nil
+	}
+
+	return &invitationJSON{
+		ID:      i.ID,
+		OrgID:   i.OrgID,
+		OrgName: i.OrgName,
+		Email:   i.Email,
+		Role:    i.Role,
+		Created: i.Created,
+		Expires: i.Expires,
+	}
+}
+
+func newWebhookJSON(w *models.Webhook) *webhookJSON {
+	if w == nil {
+		return nil
+	}
+
+	return &webhookJSON{
+		ID:      w.ID,
+		UserID:  w.UserID,
+		OrgID:   w.OrgID,
+		URL:     w.URL,
+		Events:  w.Events,
+		Created: w.Created,
+	}
+}
+
+// mapJSON converts a slice for pageJSON, keeping nil as nil so that it encodes as null.
+func mapJSON[T, J any](items []T, fn func(T) J) []J {
+	if items == nil {
+		return nil
+	}
+
+	out := make([]J, len(items))
+	for i, item := range items {
+		out[i] = fn(item)
+	}
+
+	return out
+}
+
+func newPageJSON(page string, data *templateData) *pageJSON {
+	p := &pageJSON{
+		Page:              strings.TrimSuffix(page, ".tmpl"),
+		Flash:             data.Flash,
+		IsAuthenticated:   data.IsAuthenticated,
+		AuthenticatedUser: newUserJSON(data.AuthenticatedUser),
+		Snippet:           newSnippetJSON(data.Snippet),
+		Snippets:          mapJSON(data.Snippets, newSnippetJSON),
+		User:              newUserJSON(data.User),
+		Users:             mapJSON(data.Users, newUserJSON),
+		Reports: mapJSON(data.Reports, func(r *models.ReportedSnippet) *reportJSON {
+			return &reportJSON{SnippetID: r.SnippetID, Slug: r.Slug, Title: r.Title, Hidden: r.Hidden, Reasons: r.Reasons, LastReported: r.LastReported}
+		}),
+		ModerationLog: mapJSON(data.ModerationLog, func(a *models.ModerationAction) *moderationActionJSON {
+			return &moderationActionJSON{ID: a.ID, SnippetID: a.SnippetID, ModeratorName: a.ModeratorName, Action: a.Action, Created: a.Created}
+		}),
+		Collection:        newCollectionJSON(data.Collection),
+		Collections:       mapJSON(data.Collections, newCollectionJSON),
+		CanEdit:           data.CanEdit,
+		Organization:      newOrganizationJSON(data.Organization),
+		Membership:        newMembershipJSON(data.Membership),
+		Members:           mapJSON(data.Members, newMembershipJSON),
+		Memberships:       mapJSON(data.Memberships, newMembershipJSON),
+		Invitation:        newInvitationJSON(data.Invitation),
+		Invitations:       mapJSON(data.Invitations, newInvitationJSON),
+		InvitationURL:     data.InvitationURL,
+		TemporaryPassword: data.TemporaryPassword,
+		Webhook:           newWebhookJSON(data.Webhook),
+		Webhooks:          mapJSON(data.Webhooks, newWebhookJSON),
+		Deliveries: mapJSON(data.Deliveries, func(d *models.Delivery) *deliveryJSON {
+			return &deliveryJSON{ID: d.ID, WebhookID: d.WebhookID, Event: d.Event, Payload: d.Payload, StatusCode: d.StatusCode,
+				Error: d.Error, Attempts: d.Attempts, Created: d.Created, Updated: d.Updated}
+		}),
+	}
+
+	if data.Pagination != nil {
+		p.Pagination = &paginationJSON{
+			Query:    data.Pagination.Query,
+			Page:     data.Pagination.Page,
+			PageSize: data.Pagination.PageSize,
+			Total:    data.Pagination.Total,
+			LastPage: data.Pagination.LastPage(),
+		}
+	}
+
+	// Only the errors of a form go out, its values may hold a password
+	if v := formValidator(data.Form); v != nil && !v.Valid() {
+		p.Errors = &formErrorsJSON{Fields: v.FieldErrors, Form: v.NonFieldErrors}
+	}
+
+	return p
+}
+
+// formValidator returns the validator.Validator embedded in every form struct, or nil
+// if form isn't one.
+func formValidator(form any) *validator.Validator {
+	v := reflect.ValueOf(form)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f := v.FieldByName("Validator")
+	if !f.IsValid() {
+		return nil
+	}
+
+	val, ok := f.Interface().(validator.Validator)
+	if !ok {
+		return nil
+	}
+
+	return &val
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   bool
+	}{
+		{
+			name: "No header",
+		},
+		{
+			name:   "JSON",
+			accept: "application/json",
+			want:   true,
+		},
+		{
+			name:   "Browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		},
+		{
+			name:   "Curl",
+			accept: "*/*",
+		},
+		{
+			name:   "JSON before the wildcard",
+			accept: "application/json, text/javascript, */*; q=0.01",
+			want:   true,
+		},
+		{
+			name:   "JSON below the wildcard",
+			accept: "application/json;q=0.5, */*",
+		},
+		{
+			name:   "JSON below HTML",
+			accept: "application/json;q=0.8, text/html",
+		},
+		{
+			name:   "JSON refused",
+			accept: "application/json;q=0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, wantsJSON(r), tt.want)
+		})
+	}
+}
+
+func TestPageJSON(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Home", func(t *testing.T) {
+		var page pageJSON
+
+		code, headers := ts.getJSON(t, "/", &page)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/json")
+		assert.StringContains(t, strings.Join(headers.Values("Vary"), ", "), "Accept")
+		assert.Equal(t, page.Page, "home")
+		assert.Equal(t, page.IsAuthenticated, false)
+		assert.Equal(t, len(page.Snippets), 1)
+		assert.Equal(t, page.Snippets[0].Title, "An old silent pond")
+	})
+
+	t.Run("Snippet", func(t *testing.T) {
+		var page pageJSON
+
+		code, _ := ts.getJSON(t, "/s/pond-Xq7", &page)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, page.Page, "view")
+		assert.Equal(t, page.Snippet.Slug, "pond-Xq7")
+		assert.Equal(t, page.Snippet.Content, "An old silent pond...")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		var body errorJSON
+
+		code, headers := ts.getJSON(t, "/s/missing1", &body)
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, headers.Get("Content-Type"), "application/json")
+		assert.Equal(t, body.Error, "Not Found")
+
+		code, _ = ts.getJSON(t, "/no/such/page", &body)
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, body.Error, "Not Found")
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		var body errorJSON
+
+		code, _ := ts.getJSON(t, "/snippet/view/abc", &body)
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, body.Error, "Not Found")
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		var page pageJSON
+
+		code, _ := ts.getJSON(t, "/account/view", &page)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, page.Page, "account")
+		assert.Equal(t, page.IsAuthenticated, true)
+		assert.Equal(t, page.User.Email, "alice@example.com")
+		assert.Equal(t, page.AuthenticatedUser.ID, 1)
+	})
+}
+
+func TestPageJSONFormErrors(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "wrong-password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	r, err := http.NewRequest(http.MethodPost, ts.URL+"/user/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+
+	rs, err := ts.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	var page pageJSON
+	err = json.NewDecoder(rs.Body).Decode(&page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusUnprocessableEntity)
+	assert.Equal(t, page.Page, "login")
+	assert.Equal(t, len(page.Errors.Form), 1)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shtayeb/snippetbox/internal/models/cache"
+)
+
+// metrics holds the Prometheus collectors of the application. They live in their own
+// registry rather than the global one, so that every test application gets a fresh set.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	renderDuration  *prometheus.HistogramVec
+	snippetsCreated prometheus.Counter
+	logins          *prometheus.CounterVec
+}
+
+// newMetrics creates and registers the application metrics. The connection pool
+// gauges are only registered when db isn't nil.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "Number of HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route pattern and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "snippetbox_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_template_render_duration_seconds",
+			Help:    "Time taken to execute page templates.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1},
+		}, []string{"page"}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_snippets_created_total",
+			Help: "Number of snippets created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_logins_total",
+			Help: "Number of login attempts by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.renderDuration,
+		m.snippetsCreated,
+		m.logins,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "snippetbox"))
+	}
+
+	return m
+}
+
+// registerSnippetCache exports the hit, miss and entry counts of the snippet cache,
+// read from the cache at every scrape.
+func (m *metrics) registerSnippetCache(c *cache.SnippetModel) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "snippetbox_snippet_cache_hits_total",
+			Help: "Number of snippet lookups served from the cache.",
+		}, func() float64 { return float64(c.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "snippetbox_snippet_cache_misses_total",
+			Help: "Number of snippet lookups that went to the database.",
+		}, func() float64 { return float64(c.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snippetbox_snippet_cache_entries",
+			Help: "Number of entries in the snippet cache.",
+		}, func() float64 { return float64(c.Stats().Entries) }),
+	)
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	return mux
+}
+
+const routePatternContextKey = contextKey("routePattern")
+
+// route records the httprouter pattern, like /s/:slug, of the route that
+// handles the request so that instrument can use it as a label. Raw URLs would give
+// every snippet its own time series.
+func (m *metrics) route(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := r.Context().Value(routePatternContextKey).(*string); ok {
+			*p = pattern
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// instrument counts and times every request. Requests that don't match a route,
+// like 404s, are labelled "unmatched".
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		pattern := ""
+		ctx := context.WithValue(r.Context(), routePatternContextKey, &pattern)
+		rr := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rr, r.WithContext(ctx))
+
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		if rr.status == 0 {
+			rr.status = http.StatusOK
+		}
+
+		m.requests.WithLabelValues(pattern, r.Method, strconv.Itoa(rr.status)).Inc()
+		m.requestDuration.WithLabelValues(pattern, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/s/pond-Xq7")
+	ts.get(t, "/s/missing1")
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/no/such/page")
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.metrics.handler().ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	body, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests are labelled by the route pattern rather than the raw URL
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{code="200",method="GET",route="/s/:slug"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{code="404",method="GET",route="/s/:slug"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{code="301",method="GET",route="/snippet/view/:id"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_logins_total{result="success"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_template_render_duration_seconds_count{page="view.tmpl"} 1`)
+}
@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/justinas/nosurf"
+	"github.com/shtayeb/snippetbox/internal/models"
 )
 
 func (app *application) authenticate(next http.Handler) http.Handler {
@@ -16,14 +24,16 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := app.users.Exists(id)
-		if err != nil {
-			app.serverError(w, err)
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
 			return
 		}
 
-		if exists {
+		// A disabled account is treated as logged out, which also ends any sessions it still has
+		if err == nil && !user.Disabled {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
 			r = r.WithContext(ctx)
 		}
 
@@ -54,10 +64,34 @@ func (app *application) requireAuth(next http.Handler) http.Handler {
 		// Set the Cache-Control header so that pages require auth are not stored in the users browser cache or any intermediary cache
 		w.Header().Add("Cache-Control", "no-store")
 
+		// An admin can force a password reset, until then the only pages left are the password form and logout
+		user := app.authenticatedUser(r)
+		if user.PasswordResetRequired && r.URL.Path != "/account/password/update" && r.URL.Path != "/user/logout" {
+			app.sessionManager.Put(r.Context(), "flash", "Please choose a new password before continuing.")
+			http.Redirect(w, r, "/account/password/update", http.StatusSeeOther)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// requireRole only lets through users with the given role or a higher one. It
+// goes after requireAuth, so anonymous users are sent to the login page first.
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := app.authenticatedUser(r)
+			if user == nil || !user.HasRole(role) {
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// create a defer function (which will always be run in the event of a panic as GO unwinds the stack)
@@ -69,7 +103,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 
 				// Call the app.serverError helper method to return a 500
 				// Internal Server Response
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -77,9 +111,11 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+const contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
@@ -91,10 +127,133 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) logRequest(next http.Handler) http.Handler {
+// allowFraming overrides the X-Frame-Options: deny of secureHeaders for the routes that
+// are meant to be shown in a frame on other sites. X-Frame-Options can't name more than
+// one origin, so it is dropped for the frame-ancestors directive of the CSP, which lists
+// the configured origins, or only this site when there are none.
+func (app *application) allowFraming(next http.Handler) http.Handler {
+	ancestors := "'self'"
+	if len(app.frameAncestors) > 0 {
+		ancestors = strings.Join(app.frameAncestors, " ")
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		w.Header().Del("X-Frame-Options")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy+"; frame-ancestors "+ancestors)
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestIDRX limits the X-Request-ID values we accept from clients and proxies, so
+// that nothing unexpected ends up in the logs or the response headers.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDMiddleware gives every request an ID, reusing the X-Request-ID header of the
+// request when it has a sane one, stores it in the request context and echoes it in
+// the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseRecorder wraps a http.ResponseWriter to remember the status code and
+// the number of bytes written, for logRequest.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// logRequest writes one log line per request once the handler has finished, so
+// that the line can include the response status, size and duration.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rr := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rr, r)
+
+		if rr.status == 0 {
+			rr.status = http.StatusOK
+		}
+
+		app.logger.Info("request",
+			"ip", app.clientIP(r),
+			"proto", r.Proto,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", rr.status,
+			"bytes", rr.bytes,
+			"duration", time.Since(start),
+			"request_id", requestID(r),
+		)
+	})
+}
+
+// rateLimit limits the request rate of each client to the token bucket configured for
+// the route group. Each call makes a new limiter, so the routes of a group share the
+// middleware of one call. Clients are keyed by user ID if authenticate has run before it,
+// as on the write routes, and by IP address otherwise, as in the standard chain.
+func (app *application) rateLimit(group string) func(http.Handler) http.Handler {
+	limit, ok := app.rateLimits[group]
+	if !ok || limit.RPS <= 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	limiter := newRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + app.clientIP(r)
+			if user := app.authenticatedUser(r); user != nil {
+				key = "user:" + strconv.Itoa(user.ID)
+			}
+
+			allowed, wait := limiter.allow(key, time.Now())
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				app.clientError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
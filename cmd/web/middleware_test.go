@@ -5,9 +5,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
 	"testing"
 
 	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/config"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -69,3 +72,172 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = map[string]config.RateLimit{"test": {RPS: 1, Burst: 2}}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	handler := app.rateLimit("test")(next)
+
+	// The burst lets two requests straight through, the third has to wait for a token
+	wantCodes := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+
+	for i, wantCode := range wantCodes {
+		rr := httptest.NewRecorder()
+
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = "192.0.2.1:1234"
+
+		handler.ServeHTTP(rr, r)
+
+		assert.Equal(t, rr.Code, wantCode)
+
+		if i == len(wantCodes)-1 {
+			assert.Equal(t, rr.Header().Get("Retry-After"), "1")
+		}
+	}
+
+	// Another client has its own bucket
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.RemoteAddr = "192.0.2.2:1234"
+
+	handler.ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestRateLimitWriteGroup(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimits = map[string]config.RateLimit{"write": {RPS: 0.001, Burst: 1}}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// The write routes draw on the same bucket, so the second one is already out of tokens
+	code, _, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _, _ = ts.postForm(t, "/collection/create", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestClientIP(t *testing.T) {
+	app := newTestApplication(t)
+	app.trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		want          string
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "192.0.2.1:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:          "Untrusted proxy",
+			remoteAddr:    "192.0.2.1:1234",
+			xForwardedFor: "198.51.100.7",
+			want:          "192.0.2.1",
+		},
+		{
+			name:          "Trusted proxy",
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: "198.51.100.7",
+			want:          "198.51.100.7",
+		},
+		{
+			name:          "Spoofed hop before trusted proxies",
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: "203.0.113.9, 198.51.100.7, 10.0.0.2",
+			want:          "198.51.100.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xForwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+			}
+
+			assert.Equal(t, app.clientIP(r), tt.want)
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{
+			name:     "Generated",
+			header:   "",
+			wantSame: false,
+		},
+		{
+			name:     "From header",
+			header:   "abc-123",
+			wantSame: true,
+		},
+		{
+			name:     "Invalid header",
+			header:   "abc 123\n",
+			wantSame: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+
+			// The handler sees the same ID in its context that the response carries
+			var contextID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contextID = requestID(r)
+			})
+
+			requestIDMiddleware(next).ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+
+			assert.Equal(t, id != "", true)
+			assert.Equal(t, contextID, id)
+			assert.Equal(t, id == tt.header, tt.wantSame)
+		})
+	}
+}
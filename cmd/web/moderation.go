@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// Number of moderation log entries shown under the queue
+const moderationLogSize = 20
+
+type snippetReportForm struct {
+	Reason              string `form:"reason"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetReport(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetReportForm{}
+
+	app.render(w, r, http.StatusOK, "report.tmpl", data)
+}
+
+func (app *application) snippetReportPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetReportForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "report.tmpl", data)
+		return
+	}
+
+	err = app.reports.Insert(r.Context(), snippet.ID, app.authenticatedUser(r).ID, form.Reason)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, a moderator will review your report.")
+
+	http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+}
+
+func (app *application) moderationQueue(w http.ResponseWriter, r *http.Request) {
+	reports, err := app.reports.Queue(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	log, err := app.reports.Log(r.Context(), moderationLogSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Reports = reports
+	data.ModerationLog = log
+
+	app.render(w, r, http.StatusOK, "moderation.tmpl", data)
+}
+
+// moderationActionPost applies the :action parameter (hide, restore or delete) to the
+// snippet named by the :id parameter.
+func (app *application) moderationActionPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	action := params.ByName("action")
+	if !validator.PermittedValue(action, models.ModerationHide, models.ModerationRestore, models.ModerationDelete) {
+		app.notFound(w, r)
+		return
+	}
+
+	// A deleted snippet is read first for the webhooks, like in adminSnippetDeletePost
+	var snippet *models.Snippet
+	if action == models.ModerationDelete {
+		snippet, err = app.snippets.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err = app.reports.Moderate(r.Context(), id, app.authenticatedUser(r).ID, action)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	if snippet != nil {
+		app.snippetDeletedEvent(r, snippet)
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet #%d: %s done.", id, action))
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestHiddenSnippetView(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Anonymous",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			wantCode: http.StatusOK,
+			wantBody: "This snippet has been hidden by a moderator",
+		},
+		{
+			name:     "Moderator",
+			email:    "mod@example.com",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/moderation/snippets/3/restore' method='POST'>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "pa$$word")
+			}
+
+			code, _, body := ts.get(t, "/s/frog_K2m")
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetReportPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/s/pond-Xq7/report")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		reason   string
+		wantCode int
+	}{
+		{
+			name:     "Valid report",
+			urlPath:  "/s/pond-Xq7/report",
+			reason:   "Spam",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Empty reason",
+			urlPath:  "/s/pond-Xq7/report",
+			reason:   "",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/s/missing1/report",
+			reason:   "Spam",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("reason", tt.reason)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestModerationActionPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Regular user",
+			email:    "alice@example.com",
+			urlPath:  "/moderation/snippets/1/hide",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Moderator hides",
+			email:    "mod@example.com",
+			urlPath:  "/moderation/snippets/1/hide",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Unknown action",
+			email:    "mod@example.com",
+			urlPath:  "/moderation/snippets/1/publish",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Admin deletes",
+			email:    "admin@example.com",
+			urlPath:  "/moderation/snippets/3/delete",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/s/pond-Xq7")
+			csrfToken := extractCSRFToken(t, body)
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// How many of the latest snippets of an organization its page lists
+const orgPageSnippets = 50
+
+type orgForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+type orgInviteForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+type orgRoleForm struct {
+	Role string `form:"role"`
+}
+
+// organization loads the organization named by the :id parameter of the route, and the
+// membership of the current user in it, writing a 404 if it doesn't exist.
+func (app *application) organization(w http.ResponseWriter, r *http.Request) (*models.Organization, *models.Membership, bool) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, nil, false
+	}
+
+	org, err := app.organizations.Get(r.Context(), id)
+	if err == nil {
+		var membership *models.Membership
+
+		membership, err = app.membership(r, org.ID)
+		if err == nil {
+			return org, membership, true
+		}
+	}
+
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+	} else {
+		app.serverError(w, r, err)
+	}
+
+	return nil, nil, false
+}
+
+// memberParam returns the user named by the :user parameter of the route, writing a
+// 404 if it isn't a valid id.
+func (app *application) memberParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("user"))
+	if err != nil || userID < 1 {
+		app.notFound(w, r)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// lastOwnerRedirect sends the browser back to the organization when a change was
+// refused because it would leave it without an owner.
+func (app *application) lastOwnerRedirect(w http.ResponseWriter, r *http.Request, org *models.Organization) {
+	app.sessionManager.Put(r.Context(), "flash", "The organization needs an owner, make someone else owner first.")
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", org.ID), http.StatusSeeOther)
+}
+
+func (app *application) orgCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = orgForm{}
+
+	app.render(w, r, http.StatusOK, "org_form.tmpl", data)
+}
+
+func (app *application) orgCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form orgForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "org_form.tmpl", data)
+		return
+	}
+
+	id, err := app.organizations.Insert(r.Context(), form.Name, app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Organization successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", id), http.StatusSeeOther)
+}
+
+func (app *application) orgView(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.organization(w, r)
+	if !ok {
+		return
+	}
+
+	app.renderOrg(w, r, http.StatusOK, org, membership, orgInviteForm{Role: models.OrgRoleMember})
+}
+
+// renderOrg renders the page of an organization, with its latest snippets, its members
+// and, for those who may invite, the invitation form and the pending invitations.
+func (app *application) renderOrg(w http.ResponseWriter, r *http.Request, status int, org *models.Organization, membership *models.Membership, form orgInviteForm) {
+	snippets, err := app.snippets.ByOrg(r.Context(), org.ID, orgPageSnippets)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	members, err := app.organizations.Members(r.Context(), org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Organization = org
+	data.Membership = membership
+	data.Snippets = snippets
+	data.Members = members
+	data.Form = form
+
+	if canInvite(membership, models.OrgRoleMember) {
+		data.Invitations, err = app.organizations.Invitations(r.Context(), org.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// Like the flash, the link of a new invitation is only shown once
+		data.InvitationURL = app.sessionManager.PopString(r.Context(), "invitationURL")
+		data.InvitationMailto = app.sessionManager.PopString(r.Context(), "invitationMailto")
+	}
+
+	app.render(w, r, status, "org.tmpl", data)
+}
+
+// orgInvitePost invites an email address to join the organization. There is no mail
+// server, so the link is shown to the member who invited, with a mailto: link that
+// writes the email for them.
+func (app *application) orgInvitePost(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.organization(w, r)
+	if !ok {
+		return
+	}
+
+	if !canInvite(membership, models.OrgRoleMember) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	var form orgInviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.MaxChars(form.Email, 255), "email", "This field cannot be more than 255 characters long")
+	form.CheckField(models.ValidOrgRole(form.Role), "role", "This field must equal member, maintainer or owner")
+	form.CheckField(canInvite(membership, form.Role), "role", "You can't invite with a role above your own")
+
+	if form.Valid() {
+		user, err := app.users.GetByEmail(r.Context(), form.Email)
+		if err == nil {
+			_, err = app.organizations.Member(r.Context(), org.ID, user.ID)
+			form.CheckField(errors.Is(err, models.ErrNoRecord), "email", "This user is already a member")
+		}
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.renderOrg(w, r, http.StatusUnprocessableEntity, org, membership, form)
+		return
+	}
+
+	token, err := app.organizations.Invite(r.Context(), org.ID, form.Email, form.Role, membership.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	link := app.baseURL(r) + "/invitations/" + token
+
+	app.sessionManager.Put(r.Context(), "invitationURL", link)
+	app.sessionManager.Put(r.Context(), "invitationMailto", invitationMailto(form.Email, org.Name, link))
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invitation created for %s. Send them the link below.", form.Email))
+
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", org.ID), http.StatusSeeOther)
+}
+
+// invitationMailto returns a mailto: link of an email to email with the link of the invitation.
+func invitationMailto(email, orgName, link string) string {
+	query := url.Values{
+		"subject": {"Join " + orgName + " on Snippetbox"},
+		"body":    {"You have been invited to join " + orgName + ". Open this link to accept, it works for " + strconv.Itoa(models.InvitationDays) + " days:\n\n" + link},
+	}
+
+	// mailto: wants %20 for spaces, which QueryEscape writes as +
+	return "mailto:" + url.PathEscape(email) + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func (app *application) orgMemberRolePost(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.organization(w, r)
+	if !ok {
+		return
+	}
+
+	if !canManageMembers(membership) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	userID, ok := app.memberParam(w, r)
+	if !ok {
+		return
+	}
+
+	var form orgRoleForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !models.ValidOrgRole(form.Role) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// The only owner can't give up the role, so that every organization keeps an owner
+	err = app.organizations.SetRole(r.Context(), org.ID, userID, form.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLastOwner):
+			app.lastOwnerRedirect(w, r, org)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Role changed.")
+
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", org.ID), http.StatusSeeOther)
+}
+
+// orgMemberRemovePost takes a member out of the organization, or lets a member leave.
+func (app *application) orgMemberRemovePost(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.organization(w, r)
+	if !ok {
+		return
+	}
+
+	userID, ok := app.memberParam(w, r)
+	if !ok {
+		return
+	}
+
+	if !canRemoveMember(membership, userID) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	// Nor can the only owner leave
+	err := app.organizations.RemoveMember(r.Context(), org.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLastOwner):
+			app.lastOwnerRedirect(w, r, org)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	if userID == membership.UserID {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You left %s.", org.Name))
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Member removed.")
+
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", org.ID), http.StatusSeeOther)
+}
+
+// acceptableInvitation loads the invitation named by the :token parameter of the route,
+// writing a 404 if there is no such pending invitation and a 403 if it was sent to
+// another email address than the one of the current user.
+func (app *application) acceptableInvitation(w http.ResponseWriter, r *http.Request) (*models.Invitation, bool) {
+	invitation, err := app.organizations.Invitation(r.Context(), httprouter.ParamsFromContext(r.Context()).ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	if !app.canAcceptInvitation(r, invitation) {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, false
+	}
+
+	return invitation, true
+}
+
+func (app *application) invitationView(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := app.acceptableInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Invitation = invitation
+
+	app.render(w, r, http.StatusOK, "invitation.tmpl", data)
+}
+
+func (app *application) invitationAcceptPost(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := app.acceptableInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	err := app.organizations.AcceptInvitation(r.Context(), token, app.authenticatedUser(r).ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You joined %s!", invitation.OrgName))
+
+	http.Redirect(w, r, fmt.Sprintf("/orgs/%d", invitation.OrgID), http.StatusSeeOther)
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestOrgView(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		wantNotBody []string
+	}{
+		{
+			name:        "Anonymous",
+			urlPath:     "/orgs/1",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"<h2>Haiku Club</h2>", "<a href='/s/club-Q8z'>Over the wintry forest</a>", "<td>Mo</td>"},
+			wantNotBody: []string{"Invite someone", "mod@example.com"},
+		},
+		{
+			name:        "Member",
+			email:       "mod@example.com",
+			urlPath:     "/orgs/1",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"You are a member.", "<form action='/orgs/1/members/3/remove' method='POST'>", "Leave"},
+			wantNotBody: []string{"Invite someone", "/orgs/1/members/1/remove", "Change role"},
+		},
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			urlPath:  "/orgs/1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"You are an owner.", "Invite someone", "<td>admin@example.com</td>", "<form action='/orgs/1/members/3/role' method='POST'>", "value='owner'"},
+		},
+		{
+			name:     "Non-existent organization",
+			urlPath:  "/orgs/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/orgs/club",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "pa$$word")
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+
+			for _, notWant := range tt.wantNotBody {
+				assert.Equal(t, regexp.MustCompile(regexp.QuoteMeta(notWant)).MatchString(body), false)
+			}
+		})
+	}
+}
+
+func TestOrgInvitePost(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		invite       string
+		role         string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner invites",
+			email:        "alice@example.com",
+			invite:       "bob@example.com",
+			role:         "maintainer",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/orgs/1",
+		},
+		{
+			name:     "Member",
+			email:    "mod@example.com",
+			invite:   "bob@example.com",
+			role:     "member",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Already a member",
+			email:    "alice@example.com",
+			invite:   "mod@example.com",
+			role:     "member",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Invalid email",
+			email:    "alice@example.com",
+			invite:   "bob",
+			role:     "member",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Invalid role",
+			email:    "alice@example.com",
+			invite:   "bob@example.com",
+			role:     "admin",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/orgs/1")
+
+			form := url.Values{}
+			form.Add("email", tt.invite)
+			form.Add("role", tt.role)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, "/orgs/1/invitations", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantCode == http.StatusSeeOther {
+				// The link is shown once, to be sent to the invited address
+				_, _, body = ts.get(t, "/orgs/1")
+				assert.StringContains(t, body, "/invitations/new-invitation-token' readonly>")
+				assert.StringContains(t, body, "href='mailto:bob@example.com?body=")
+
+				_, _, body = ts.get(t, "/orgs/1")
+				assert.Equal(t, regexp.MustCompile("new-invitation-token").MatchString(body), false)
+			}
+		})
+	}
+}
+
+func TestOrgMemberPost(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		role         string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner changes a role",
+			email:        "alice@example.com",
+			urlPath:      "/orgs/1/members/3/role",
+			role:         "maintainer",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/orgs/1",
+		},
+		{
+			name:     "Member changes a role",
+			email:    "mod@example.com",
+			urlPath:  "/orgs/1/members/3/role",
+			role:     "owner",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Invalid role",
+			email:    "alice@example.com",
+			urlPath:  "/orgs/1/members/3/role",
+			role:     "admin",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Not a member",
+			email:    "alice@example.com",
+			urlPath:  "/orgs/1/members/2/role",
+			role:     "member",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Owner removes a member",
+			email:        "alice@example.com",
+			urlPath:      "/orgs/1/members/3/remove",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/orgs/1",
+		},
+		{
+			name:         "Member leaves",
+			email:        "mod@example.com",
+			urlPath:      "/orgs/1/members/3/remove",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+		},
+		{
+			name:     "Member removes the owner",
+			email:    "mod@example.com",
+			urlPath:  "/orgs/1/members/1/remove",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Outsider",
+			email:    "admin@example.com",
+			urlPath:  "/orgs/1/members/3/remove",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/orgs/1")
+
+			form := url.Values{}
+			form.Add("role", tt.role)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestOrgLastOwner(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/orgs/1")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// The only owner can neither leave nor step down
+	code, _, _ := ts.postForm(t, "/orgs/1/members/1/remove", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/orgs/1")
+	assert.StringContains(t, body, "The organization needs an owner")
+
+	form.Add("role", "member")
+
+	code, _, _ = ts.postForm(t, "/orgs/1/members/1/role", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/orgs/1")
+	assert.StringContains(t, body, "The organization needs an owner")
+}
+
+func TestInvitation(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Invited user",
+			email:    "admin@example.com",
+			urlPath:  "/invitations/invitation-token",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Other user",
+			email:    "mod@example.com",
+			urlPath:  "/invitations/invitation-token",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Unknown token",
+			email:    "admin@example.com",
+			urlPath:  "/invitations/expired-token",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code != http.StatusOK {
+				return
+			}
+
+			assert.StringContains(t, body, "You have been invited to join Haiku Club as a maintainer.")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/orgs/1")
+		})
+	}
+}
+
+func TestInvitationAnonymous(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The link sends a visitor to log in first, and back to the invitation after
+	code, headers, _ := ts.get(t, "/invitations/invitation-token")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login?next=/invitations/invitation-token")
+}
+
+func TestSnippetEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner of a personal snippet",
+			email:    "alice@example.com",
+			urlPath:  "/s/pond-Xq7/edit",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Other user of a personal snippet",
+			email:    "mod@example.com",
+			urlPath:  "/s/pond-Xq7/edit",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Member who created an organization snippet",
+			email:    "mod@example.com",
+			urlPath:  "/s/club-Q8z/edit",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Owner of the organization",
+			email:    "alice@example.com",
+			urlPath:  "/s/club-Q8z/edit",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Outsider of the organization",
+			email:    "admin@example.com",
+			urlPath:  "/s/club-Q8z/edit",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent snippet",
+			email:    "alice@example.com",
+			urlPath:  "/s/missing1/edit",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code != http.StatusOK {
+				return
+			}
+
+			assert.StringContains(t, body, "<form action='"+tt.urlPath+"' method='POST'>")
+			csrfToken := extractCSRFToken(t, body)
+
+			form := url.Values{}
+			form.Add("title", "")
+			form.Add("content", "Over the wintry forest")
+			form.Add("content_type", "text")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ = ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+			form.Set("title", "Winter")
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.urlPath[:len("/s/pond-Xq7")])
+		})
+	}
+}
+
+func TestSnippetViewOrganization(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/s/club-Q8z")
+	assert.StringContains(t, body, "Shared by <a href='/orgs/1'>Haiku Club</a>")
+	assert.Equal(t, regexp.MustCompile("Edit this snippet").MatchString(body), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/s/club-Q8z")
+	assert.StringContains(t, body, "<a href='/s/club-Q8z/edit'>Edit this snippet</a>")
+}
+
+func TestOrgFlow(t *testing.T) {
+	// The memory backend keeps what the requests write, so an invitation can be followed to the end
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	assert.NilError(t, app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"))
+	assert.NilError(t, app.users.Insert(ctx, "Bob", "bob@example.com", "validPa$$word"))
+
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/org/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("name", "Ops")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/org/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/orgs/1")
+
+	// Alice creates a snippet for the organization from the create form
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='1' >Ops</option>")
+
+	form = url.Values{}
+	form.Add("title", "Deploy")
+	form.Add("content", "make deploy")
+	form.Add("content_type", "text")
+	form.Add("expires", "7")
+	form.Add("org", "1")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetPath := headers.Get("Location")
+
+	form = url.Values{}
+	form.Add("email", "bob@example.com")
+	form.Add("role", "member")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/orgs/1/invitations", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/orgs/1")
+	link := regexp.MustCompile(`value='https://[^/]+(/invitations/[A-Za-z0-9_-]+)' readonly>`).FindStringSubmatch(body)
+	if link == nil {
+		t.Fatalf("no invitation link in %q", body)
+	}
+
+	code, _, _ = ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Bob accepts, and as a member can't edit a snippet that Alice created
+	ts.login(t, "bob@example.com", "validPa$$word")
+
+	_, _, body = ts.get(t, link[1])
+	csrfToken = extractCSRFToken(t, body)
+
+	code, headers, _ = ts.postForm(t, link[1], url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/orgs/1")
+
+	// The link was used up
+	code, _, _ = ts.get(t, link[1])
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body = ts.get(t, "/account/view")
+	assert.StringContains(t, body, "<a href='/orgs/1'>Ops</a>")
+
+	code, _, _ = ts.get(t, snippetPath+"/edit")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	// Once a maintainer, he can
+	assert.NilError(t, app.organizations.SetRole(ctx, 1, 2, "maintainer"))
+
+	code, _, _ = ts.get(t, snippetPath+"/edit")
+	assert.Equal(t, code, http.StatusOK)
+
+	form = url.Values{}
+	form.Add("title", "Deploy to production")
+	form.Add("content", "make deploy ENV=production")
+	form.Add("content_type", "text")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, snippetPath+"/edit", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/orgs/1")
+	assert.StringContains(t, body, ">Deploy to production</a>")
+}
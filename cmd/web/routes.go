@@ -5,6 +5,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
+	"github.com/shtayeb/snippetbox/internal/models"
 	"github.com/shtayeb/snippetbox/ui"
 )
 
@@ -20,9 +21,14 @@ func (app *application) routes() http.Handler {
 
 	router := httprouter.New()
 
+	// handle registers a route, recording its pattern so the metrics can be labelled by route
+	handle := func(method, pattern string, handler http.Handler) {
+		router.Handler(method, pattern, app.metrics.route(pattern, handler))
+	}
+
 	// Custom error handler methods
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFound(w)
+		app.notFound(w, r)
 	})
 
 	// relative to the project directory
@@ -34,33 +40,114 @@ func (app *application) routes() http.Handler {
 	//
 	fileServer := http.FileServer(http.FS(ui.Files))
 	// router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
+	handle(http.MethodGet, "/static/*filepath", fileServer)
+
+	handle(http.MethodGet, "/ping", http.HandlerFunc(ping))
+	handle(http.MethodGet, "/healthz", http.HandlerFunc(app.healthz))
+	handle(http.MethodGet, "/readyz", http.HandlerFunc(app.readyz))
+
+	// The feeds are public and the same for everyone, so they skip the session and CSRF middleware.
+	// The per-user ones are under /users since httprouter can't mix /user/:id with /user/login.
+	handle(http.MethodGet, "/feed.atom", http.HandlerFunc(app.feedLatest))
+	handle(http.MethodGet, "/feed.rss", http.HandlerFunc(app.feedLatest))
+	handle(http.MethodGet, "/users/:id/feed.atom", http.HandlerFunc(app.feedUser))
+	handle(http.MethodGet, "/users/:id/feed.rss", http.HandlerFunc(app.feedUser))
 
-	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	// The embeds and oEmbed are fetched by other sites, so they go without a session too. The
+	// embeds are the only pages that may be shown in a frame, by the frame-ancestors origins.
+	handle(http.MethodGet, "/s/:slug/embed", app.allowFraming(http.HandlerFunc(app.snippetEmbed)))
+	handle(http.MethodGet, "/snippet/embed/:id", app.snippetRedirect("/embed"))
+	handle(http.MethodGet, "/oembed", http.HandlerFunc(app.oembed))
 
 	// /static/ - is subtree path. subtree paths end with /
 	// /test - is redirected to /test/. if a subtree is registered
 	// router.Handle("/static/", http.StripPrefix("/static", fileServer))
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
-	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	// The write routes share one budget per client, so the limiter is made once for all of them
+	writeLimit := app.rateLimit("write")
+
+	handle(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	handle(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
+	// Snippets are addressed by their random slug. The numeric addresses they had before
+	// redirect there, for the links that are already out.
+	handle(http.MethodGet, "/s/:slug", dynamic.ThenFunc(app.snippetView))
+	handle(http.MethodGet, "/snippet/view/:id", dynamic.Then(app.snippetRedirect("")))
+	// Private collections are only found by their owner, the public ones by anyone
+	handle(http.MethodGet, "/collections/:id", dynamic.ThenFunc(app.collectionView))
+	handle(http.MethodGet, "/collections/:id/export", dynamic.ThenFunc(app.collectionExport))
+	handle(http.MethodGet, "/orgs/:id", dynamic.ThenFunc(app.orgView))
+	handle(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
+	handle(http.MethodPost, "/user/signup", dynamic.Append(writeLimit).ThenFunc(app.userSignupPost))
+	handle(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
+	handle(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
 
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
 	protected := dynamic.Append(app.requireAuth)
 
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
-	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
-	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
-	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
-	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	handle(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
+	handle(http.MethodPost, "/snippet/create", protected.Append(writeLimit).ThenFunc(app.snippetCreatePost))
+	handle(http.MethodPost, "/snippet/preview", protected.ThenFunc(app.snippetPreviewPost))
+	handle(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	handle(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	handle(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	handle(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	handle(http.MethodGet, "/s/:slug/report", protected.ThenFunc(app.snippetReport))
+	handle(http.MethodPost, "/s/:slug/report", protected.Append(writeLimit).ThenFunc(app.snippetReportPost))
+	handle(http.MethodPost, "/s/:slug/collections", protected.ThenFunc(app.snippetCollectPost))
+	// Who may edit a snippet is up to canEditSnippet
+	handle(http.MethodGet, "/s/:slug/edit", protected.ThenFunc(app.snippetEdit))
+	handle(http.MethodPost, "/s/:slug/edit", protected.ThenFunc(app.snippetEditPost))
+
+	// Collections are changed by their owner only, which the handlers check. They are created at the
+	// singular /collection/create, like the snippets, since httprouter can't mix it with /collections/:id.
+	handle(http.MethodGet, "/collection/create", protected.ThenFunc(app.collectionCreate))
+	handle(http.MethodPost, "/collection/create", protected.Append(writeLimit).ThenFunc(app.collectionCreatePost))
+	handle(http.MethodGet, "/collections/:id/edit", protected.ThenFunc(app.collectionEdit))
+	handle(http.MethodPost, "/collections/:id/edit", protected.ThenFunc(app.collectionEditPost))
+	handle(http.MethodPost, "/collections/:id/delete", protected.ThenFunc(app.collectionDeletePost))
+	handle(http.MethodPost, "/collections/:id/snippets/:slug/:action", protected.ThenFunc(app.collectionSnippetPost))
+
+	// Organizations are created at /org/create for the same reason. What a member may do in one
+	// depends on their role in it, which the handlers check with authz.go. Invitations are
+	// accepted by the user whose email address they were sent to, once logged in.
+	handle(http.MethodGet, "/org/create", protected.ThenFunc(app.orgCreate))
+	handle(http.MethodPost, "/org/create", protected.Append(writeLimit).ThenFunc(app.orgCreatePost))
+	handle(http.MethodPost, "/orgs/:id/invitations", protected.Append(writeLimit).ThenFunc(app.orgInvitePost))
+	handle(http.MethodPost, "/orgs/:id/members/:user/role", protected.ThenFunc(app.orgMemberRolePost))
+	handle(http.MethodPost, "/orgs/:id/members/:user/remove", protected.ThenFunc(app.orgMemberRemovePost))
+	handle(http.MethodGet, "/invitations/:token", protected.ThenFunc(app.invitationView))
+	handle(http.MethodPost, "/invitations/:token", protected.ThenFunc(app.invitationAcceptPost))
+
+	// Webhooks get the events of the personal snippets of a user, or of the snippets of an
+	// organization. Who may see and change one is up to canManageWebhook.
+	handle(http.MethodGet, "/account/webhooks", protected.ThenFunc(app.accountWebhooks))
+	handle(http.MethodPost, "/account/webhooks", protected.Append(writeLimit).ThenFunc(app.accountWebhooksPost))
+	handle(http.MethodGet, "/orgs/:id/webhooks", protected.ThenFunc(app.orgWebhooks))
+	handle(http.MethodPost, "/orgs/:id/webhooks", protected.Append(writeLimit).ThenFunc(app.orgWebhooksPost))
+	handle(http.MethodGet, "/webhooks/:id", protected.ThenFunc(app.webhookView))
+	handle(http.MethodPost, "/webhooks/:id/delete", protected.ThenFunc(app.webhookDeletePost))
+	handle(http.MethodPost, "/webhooks/:id/deliveries/:delivery/redeliver", protected.Append(writeLimit).ThenFunc(app.webhookRedeliverPost))
+
+	// Moderators (and admins, which outrank them) work through the queue of reported snippets.
+	moderator := protected.Append(app.requireRole(models.RoleModerator))
+
+	handle(http.MethodGet, "/moderation", moderator.ThenFunc(app.moderationQueue))
+	handle(http.MethodPost, "/moderation/snippets/:id/:action", moderator.ThenFunc(app.moderationActionPost))
+
+	// Admin-only routes. requireRole goes after requireAuth so anonymous users are sent to the login page.
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+
+	handle(http.MethodGet, "/admin", admin.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+	}))
+	handle(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	handle(http.MethodPost, "/admin/users/:id/disable", admin.ThenFunc(app.adminUserDisablePost))
+	handle(http.MethodPost, "/admin/users/:id/enable", admin.ThenFunc(app.adminUserEnablePost))
+	handle(http.MethodPost, "/admin/users/:id/reset-password", admin.ThenFunc(app.adminUserResetPasswordPost))
+	handle(http.MethodGet, "/admin/snippets", admin.ThenFunc(app.adminSnippets))
+	handle(http.MethodPost, "/admin/snippets/:id/delete", admin.ThenFunc(app.adminSnippetDeletePost))
 
 	// pass the servemux as the 'next' parameter to the secureHeaders middleware.
 	// because secureHeaders is just a function, and the function returns a http.Handler
@@ -70,7 +157,12 @@ func (app *application) routes() http.Handler {
 
 	// Alice middleware
 	// Create a middleware chain containing our 'standard' middleware which will be used for every request our appliction receives.
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// The request ID comes first so that every other middleware can log it, and the metrics come next
+	// so that they time the whole request. recoverPanic goes
+	// inside logRequest so that the 500 it sends is what gets logged. The global rate limit goes
+	// after logRequest so that blocked requests are still logged. It comes before the session is
+	// loaded, so it is keyed by IP address only.
+	standard := alice.New(requestIDMiddleware, app.metrics.instrument, app.logRequest, app.recoverPanic, secureHeaders, app.rateLimit("global"))
 
 	// return the 'standard' middleware chain followed by the servemux
 	return standard.Then(router)
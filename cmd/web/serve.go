@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the server until it fails or the process receives SIGINT or SIGTERM,
+// then shuts it down.
+func (app *application) serve(srv *http.Server, certFile, keyFile string, delay, timeout time.Duration) error {
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String(), "delay", delay, "timeout", timeout)
+
+		shutdownErr <- app.shutdown(srv, delay, timeout, quit)
+	}()
+
+	app.logger.Info("starting server", "addr", srv.Addr)
+
+	// ListenAndServeTLS returns http.ErrServerClosed straight away when Shutdown is called,
+	// the shutdown itself is only over once the goroutine above reports back.
+	err := srv.ListenAndServeTLS(certFile, keyFile)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server")
+
+	return nil
+}
+
+// shutdown marks the application as shutting down, so readyz fails, and keeps serving
+// for delay so that load balancers see it and stop sending requests. A second signal on
+// quit cuts the delay short. It then stops accepting connections and waits for the
+// in-flight requests and background tasks, which share the timeout. The background tasks
+// get whatever is left of it even if the requests didn't finish in time. It returns an
+// error if either of them didn't.
+func (app *application) shutdown(srv *http.Server, delay, timeout time.Duration, quit <-chan os.Signal) error {
+	app.shuttingDown.Store(true)
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-quit:
+			timer.Stop()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("shutting down server: %w", err))
+	}
+
+	// The webhook deliveries waiting for a retry give up, the rest are waited for
+	app.webhookSender.Stop()
+
+	app.logger.Info("waiting for background tasks")
+
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for background tasks: %w", ctx.Err()))
+	}
+
+	return errors.Join(errs...)
+}
+
+// background runs fn in a goroutine that graceful shutdown waits for. A panic in
+// fn is logged rather than taking the whole process down.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
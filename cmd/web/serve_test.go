@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	app := newTestApplication(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	srv := &http.Server{Handler: app.routes()}
+	go srv.Serve(ln)
+
+	// A background task that outlives the requests is still waited for
+	release := make(chan struct{})
+	finished := false
+	app.background(func() {
+		<-release
+		finished = true
+	})
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- app.shutdown(srv, 200*time.Millisecond, 5*time.Second, make(chan os.Signal))
+	}()
+
+	// During the delay the server still answers, with readyz failing
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/readyz")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+
+	close(release)
+
+	assert.NilError(t, <-shutdownErr)
+	assert.Equal(t, finished, true)
+
+	_, err = http.Get("http://" + ln.Addr().String() + "/readyz")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	app := newTestApplication(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	srv := &http.Server{Handler: app.routes()}
+	go srv.Serve(ln)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	app.background(func() {
+		<-release
+	})
+
+	err = app.shutdown(srv, 0, 50*time.Millisecond, make(chan os.Signal))
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "waiting for background tasks")
+}
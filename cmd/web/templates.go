@@ -1,27 +1,113 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
+	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"time"
 
+	"github.com/shtayeb/snippetbox/internal/markdown"
 	"github.com/shtayeb/snippetbox/internal/models"
-	"github.com/shtayeb/snippetbox/ui"
 )
 
 // Define a templateData type to act as the holding structure for
 // any dynamic data that we want to pass to our HTML templates.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            *models.User
+	CurrentYear int
+	// The scheme and host of the site, for the addresses that are used on other sites
+	BaseURL           string
+	Snippet           *models.Snippet
+	Snippets          []*models.Snippet
+	Form              any
+	Flash             string
+	IsAuthenticated   bool
+	AuthenticatedUser *models.User
+	CSRFToken         string
+	User              *models.User
+	Users             []*models.User
+	Pagination        *pagination
+	Reports           []*models.ReportedSnippet
+	ModerationLog     []*models.ModerationAction
+	Collection        *models.Collection
+	Collections       []*models.Collection
+	// The collections of the logged in user, that the snippet on the page can be added to
+	OwnCollections []*models.Collection
+	// Whether the logged in user may edit the snippet on the page
+	CanEdit      bool
+	Organization *models.Organization
+	// The membership of the logged in user in the organization on the page, nil if they aren't a member
+	Membership  *models.Membership
+	Members     []*models.Membership
+	Memberships []*models.Membership
+	Invitation  *models.Invitation
+	Invitations []*models.Invitation
+	// The link of the invitation just sent, shown once to the member who sent it, and a
+	// mailto: link that writes the email with it
+	InvitationURL    string
+	InvitationMailto string
+	// The temporary password an admin just gave User, only ever put in this response
+	TemporaryPassword string
+	Webhook           *models.Webhook
+	Webhooks          []*models.Webhook
+	Deliveries        []*models.Delivery
+}
+
+// pagination holds the search query and page position of a paginated listing.
+type pagination struct {
+	Query    string
+	Page     int
+	PageSize int
+	Total    int
+}
+
+// The highest page number a listing accepts, which keeps the offset far from overflowing
+const maxPage = 1 << 20
+
+// newPagination reads the "q" and "page" query string parameters of the request.
+// Missing or invalid page numbers fall back to the first page, and larger ones than
+// maxPage to maxPage.
+func newPagination(r *http.Request, pageSize int) *pagination {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	page = min(page, maxPage)
+
+	return &pagination{
+		Query:    r.URL.Query().Get("q"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+}
+
+func (p *pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+func (p *pagination) LastPage() int {
+	return max(1, (p.Total+p.PageSize-1)/p.PageSize)
+}
+
+func (p *pagination) Previous() int {
+	return p.Page - 1
+}
+
+func (p *pagination) Next() int {
+	return p.Page + 1
+}
+
+func (p *pagination) HasPrevious() bool {
+	return p.Page > 1
+}
+
+func (p *pagination) HasNext() bool {
+	return p.Page < p.LastPage()
 }
 
 // custom template functions (like
@@ -39,17 +125,36 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// renderContent returns the HTML of the content of a snippet: markdown rendered and
+// sanitized, anything else escaped as preformatted text.
+func renderContent(s *models.Snippet) (template.HTML, error) {
+	if s.ContentType == models.ContentTypeMarkdown {
+		return markdown.Render(s.Content)
+	}
+
+	return template.HTML("<pre><code>" + template.HTMLEscapeString(s.Content) + "</code></pre>"), nil
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"renderContent": renderContent,
+	"embedCode":     embedCode,
+	// The pages only offer what the handlers will allow, from the same rules in authz.go
+	"canInvite":         canInvite,
+	"canManageMembers":  canManageMembers,
+	"canRemoveMember":   canRemoveMember,
+	"canManageWebhooks": canManageWebhooks,
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// newTemplateCache parses the page templates of fsys, which holds the html directory:
+// ui.Files in production, and the ui directory on disk in debug mode.
+func newTemplateCache(fsys fs.FS) (map[string]*template.Template, error) {
 	// Initialize a new map to act as the cache
 	cache := map[string]*template.Template{}
 
 	// filepath.Glob() function to get a slice of all filepaths that match the pattern "./ui/html/pages/*.tmpl
 	// like: [ui/html/pages/home.tmpl ui/html/pages/view.tmpl
-	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	pages, err := fs.Glob(fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -59,8 +164,9 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// extract the filename (like 'home.tmpl') from the full path
 		name := filepath.Base(page)
 
+		// Every page gets every layout, base.tmpl for the site and frame.tmpl for the embeds
 		patterns := []string{
-			"html/base.tmpl",
+			"html/*.tmpl",
 			"html/partials/*.tmpl",
 			page,
 		}
@@ -68,7 +174,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// Parse the base template file into a template set
 		// ts, err := template.ParseFiles("./ui/html/base.tmpl")
 		// ts, err := template.New(name).Funcs(functions).ParseFiles("./ui/html/base.tmpl")
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		ts, err := template.New(name).Funcs(functions).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -79,3 +185,65 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	// return the cache map
 	return cache, nil
 }
+
+// templateReloader reparses the templates whenever one of them changes on disk, so that
+// debug mode doesn't need a restart after each edit. The files are checked on every
+// render, which for a dozen templates is cheap next to the render itself.
+type templateReloader struct {
+	fsys fs.FS
+
+	mu sync.Mutex
+	// The names, sizes and modification times of the files the cache was parsed from
+	fingerprint string
+	cache       map[string]*template.Template
+}
+
+func newTemplateReloader(fsys fs.FS) *templateReloader {
+	return &templateReloader{fsys: fsys}
+}
+
+// templates returns the template cache, parsing it again if the files changed since
+// the last call. A parse error is returned until the templates are fixed.
+func (tr *templateReloader) templates() (map[string]*template.Template, error) {
+	fingerprint, err := tr.fingerprintFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.cache != nil && fingerprint == tr.fingerprint {
+		return tr.cache, nil
+	}
+
+	cache, err := newTemplateCache(tr.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.cache = cache
+	tr.fingerprint = fingerprint
+
+	return cache, nil
+}
+
+func (tr *templateReloader) fingerprintFiles() (string, error) {
+	var b strings.Builder
+
+	err := fs.WalkDir(tr.fsys, "html", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	return b.String(), err
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/shtayeb/snippetbox/internal/assert"
@@ -43,3 +47,102 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       string
+		wantPage   int
+		wantOffset int
+	}{
+		{name: "Missing", page: "", wantPage: 1, wantOffset: 0},
+		{name: "Invalid", page: "two", wantPage: 1, wantOffset: 0},
+		{name: "Negative", page: "-3", wantPage: 1, wantOffset: 0},
+		{name: "Third", page: "3", wantPage: 3, wantOffset: 40},
+		// The offset of the largest int would overflow to a negative number
+		{name: "Huge", page: "9223372036854775807", wantPage: maxPage, wantOffset: (maxPage - 1) * 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/users?page="+tt.page, nil)
+
+			p := newPagination(r, 20)
+
+			assert.Equal(t, p.Page, tt.wantPage)
+			assert.Equal(t, p.Offset(), tt.wantOffset)
+		})
+	}
+}
+
+func TestTemplateReloader(t *testing.T) {
+	modified := time.Now()
+
+	fsys := fstest.MapFS{
+		"html/base.tmpl":         {Data: []byte(`{{define "base"}}<h1>{{template "title" .}}</h1>{{end}}`), ModTime: modified},
+		"html/partials/nav.tmpl": {Data: []byte(`{{define "nav"}}{{end}}`), ModTime: modified},
+		"html/pages/home.tmpl":   {Data: []byte(`{{define "title"}}Home{{end}}`), ModTime: modified},
+		"html/pages/unused.tmpl": {Data: []byte(`{{define "title"}}Unused{{end}}`), ModTime: modified},
+		"static/css/main.css":    {Data: []byte(`body {}`), ModTime: modified},
+	}
+
+	tr := newTemplateReloader(fsys)
+
+	execute := func() string {
+		t.Helper()
+
+		cache, err := tr.templates()
+		assert.NilError(t, err)
+
+		var buf bytes.Buffer
+		err = cache["home.tmpl"].ExecuteTemplate(&buf, "base", nil)
+		assert.NilError(t, err)
+
+		return buf.String()
+	}
+
+	assert.Equal(t, execute(), "<h1>Home</h1>")
+
+	// The same files give back the same cache
+	first, _ := tr.templates()
+	second, _ := tr.templates()
+	assert.Equal(t, first["home.tmpl"], second["home.tmpl"])
+
+	modified = modified.Add(time.Second)
+	fsys["html/pages/home.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "title"}}Welcome{{end}}`), ModTime: modified}
+	assert.Equal(t, execute(), "<h1>Welcome</h1>")
+
+	// A broken template is reported until it is fixed
+	modified = modified.Add(time.Second)
+	fsys["html/base.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "base"}}<h1>{{template "title" .}</h1>{{end}}`), ModTime: modified}
+
+	for range 2 {
+		_, err := tr.templates()
+		if err == nil {
+			t.Fatal("got: nil; expected a parse error")
+		}
+	}
+
+	modified = modified.Add(time.Second)
+	fsys["html/base.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "base"}}<h2>{{template "title" .}}</h2>{{end}}`), ModTime: modified}
+	assert.Equal(t, execute(), "<h2>Welcome</h2>")
+}
+
+func TestRenderParseError(t *testing.T) {
+	app := newTestApplication(t)
+	app.debug = true
+	app.templateReloader = newTemplateReloader(fstest.MapFS{
+		"html/base.tmpl":         {Data: []byte(`{{define "base"}}{{template "main" .}}{{end}}`)},
+		"html/partials/nav.tmpl": {Data: []byte(`{{define "nav"}}{{end}}`)},
+		"html/pages/home.tmpl":   {Data: []byte(`{{define "main"}}{{if}}{{end}}`)},
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.render(rr, r, http.StatusOK, "home.tmpl", &templateData{})
+
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.StringContains(t, rr.Body.String(), "home.tmpl")
+	assert.StringContains(t, rr.Body.String(), "missing value for if")
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
 	"testing"
 	"time"
@@ -15,6 +18,8 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	"github.com/shtayeb/snippetbox/internal/models/mocks"
+	"github.com/shtayeb/snippetbox/internal/storage"
+	"github.com/shtayeb/snippetbox/ui"
 )
 
 // Define a regular expr which captures the CSRF token from HTML
@@ -34,7 +39,7 @@ func extractCSRFToken(t *testing.T, body string) string {
 // application struct containing mocked dependencies.
 func newTestApplication(t *testing.T) *application {
 	// Create an instance of the template cache
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(ui.Files)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,20 +49,63 @@ func newTestApplication(t *testing.T) *application {
 
 	// SessionManager instance, We dont set a store. by default SCS package uses a transient in-memonry store
 	sessionManager := scs.New()
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Lifetime = 30 * 24 * time.Hour
+	sessionManager.IdleTimeout = 7 * 24 * time.Hour
+	sessionManager.Cookie.Persist = false
 	sessionManager.Cookie.Secure = true
 
+	// The webhook receivers of the tests listen on 127.0.0.1
+	webhookSender := newWebhookSender()
+	webhookSender.allowPrivate = true
+
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		db:              &fakeDB{},
+		snippets:        &mocks.SnippetModel{},
+		users:           &mocks.UserModel{},
+		loginAttempts:   &mocks.LoginAttemptModel{},
+		reports:         &mocks.ReportModel{},
+		collections:     &mocks.CollectionModel{},
+		organizations:   &mocks.OrganizationModel{},
+		webhooks:        &mocks.WebhookModel{},
+		templateCache:   templateCache,
+		feeds:           newFeedCache(),
+		webhookSender:   webhookSender,
+		formDecoder:     formDecoder,
+		sessionManager:  sessionManager,
+		metrics:         newMetrics(nil),
+		sessionLifetime: 12 * time.Hour,
+		loginLimits:     defaultLoginLimits,
 	}
 }
 
+// newMemoryTestApplication returns a test application on the memory storage backend,
+// for tests that follow a flow across several requests. It starts without any users.
+func newMemoryTestApplication(t *testing.T) *application {
+	app := newTestApplication(t)
+
+	store := storage.OpenMemory()
+	app.db = store
+	app.snippets = store.Snippets
+	app.users = store.Users
+	app.loginAttempts = store.LoginAttempts
+	app.reports = store.Reports
+	app.collections = store.Collections
+	app.organizations = store.Organizations
+	app.webhooks = store.Webhooks
+
+	return app
+}
+
+// fakeDB stands in for the database connection pool in the health checks.
+type fakeDB struct {
+	err error
+}
+
+func (db *fakeDB) PingContext(ctx context.Context) error {
+	return db.err
+}
+
 // Define a custom testServer type which embeds a httptest.Server instance
 type testServer struct {
 	*httptest.Server
@@ -101,3 +149,42 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// getJSON sends a GET request that prefers JSON and decodes the response body into dst.
+func (ts *testServer) getJSON(t *testing.T, urlPath string, dst any) (int, http.Header) {
+	r, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Accept", "application/json")
+
+	rs, err := ts.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+
+	err = json.NewDecoder(rs.Body).Decode(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header
+}
+
+// login logs the test server client in with the given credentials from the mock user model.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s failed with status %d", email, code)
+	}
+}
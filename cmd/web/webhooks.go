@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shtayeb/snippetbox/internal/models"
+	validator "github.com/shtayeb/snippetbox/internal/validator"
+)
+
+// How many of the latest deliveries of a webhook its page lists
+const webhookPageDeliveries = 50
+
+type webhookForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"events"`
+	validator.Validator `form:"-"`
+}
+
+// Wants reports whether event is ticked, for the checkboxes of the form.
+func (f webhookForm) Wants(event string) bool {
+	return slices.Contains(f.Events, event)
+}
+
+func (f *webhookForm) check() {
+	f.CheckField(validator.NotBlank(f.URL), "url", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	f.CheckField(validator.WebURL(f.URL), "url", "This field must be an http or https URL")
+
+	f.CheckField(len(f.Events) > 0, "events", "Pick at least one event")
+	for _, event := range f.Events {
+		f.CheckField(validator.PermittedValue(event, models.WebhookEvents...), "events", "This field must only hold snippet events")
+	}
+}
+
+// webhook loads the webhook named by the :id parameter of the route, writing a 404 if
+// it doesn't exist and a 403 if the current user may not manage it.
+func (app *application) webhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	webhook, err := app.webhooks.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	ok, err := app.canManageWebhook(r, webhook)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	if !ok {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// webhooksOrg loads the organization of the route for its webhook pages, writing a 403
+// unless the current user manages its webhooks.
+func (app *application) webhooksOrg(w http.ResponseWriter, r *http.Request) (*models.Organization, *models.Membership, bool) {
+	org, membership, ok := app.organization(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !canManageWebhooks(membership) {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return org, membership, true
+}
+
+// renderWebhooks renders the webhooks of the current user, or of org if it isn't nil,
+// with the form that adds one.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, org *models.Organization, membership *models.Membership, form webhookForm) {
+	var webhooks []*models.Webhook
+	var err error
+
+	if org != nil {
+		webhooks, err = app.webhooks.ByOrg(r.Context(), org.ID)
+	} else {
+		webhooks, err = app.webhooks.ByUser(r.Context(), app.authenticatedUser(r).ID)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Organization = org
+	data.Membership = membership
+	data.Webhooks = webhooks
+	data.Form = form
+
+	app.render(w, r, status, "webhooks.tmpl", data)
+}
+
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, http.StatusOK, nil, nil, webhookForm{Events: models.WebhookEvents})
+}
+
+func (app *application) accountWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	app.createWebhook(w, r, nil, nil)
+}
+
+func (app *application) orgWebhooks(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.webhooksOrg(w, r)
+	if !ok {
+		return
+	}
+
+	app.renderWebhooks(w, r, http.StatusOK, org, membership, webhookForm{Events: models.WebhookEvents})
+}
+
+func (app *application) orgWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	org, membership, ok := app.webhooksOrg(w, r)
+	if !ok {
+		return
+	}
+
+	app.createWebhook(w, r, org, membership)
+}
+
+// createWebhook adds the webhook of the form for the current user, or for org if it
+// isn't nil, and sends the browser to its page, which shows the secret.
+func (app *application) createWebhook(w http.ResponseWriter, r *http.Request, org *models.Organization, membership *models.Membership) {
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.check()
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, org, membership, form)
+		return
+	}
+
+	userID, orgID := app.authenticatedUser(r).ID, 0
+	if org != nil {
+		userID, orgID = 0, org.ID
+	}
+
+	id, err := app.webhooks.Insert(r.Context(), userID, orgID, form.URL, form.Events)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/%d", id), http.StatusSeeOther)
+}
+
+// webhookView shows a webhook with its secret and the log of its latest deliveries.
+func (app *application) webhookView(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = webhook
+
+	if webhook.OrgID != 0 {
+		var err error
+
+		data.Organization, err = app.organizations.Get(r.Context(), webhook.OrgID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	deliveries, err := app.webhooks.Deliveries(r.Context(), webhook.ID, webhookPageDeliveries)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Deliveries = deliveries
+
+	app.render(w, r, http.StatusOK, "webhook.tmpl", data)
+}
+
+func (app *application) webhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.webhooks.Delete(r.Context(), webhook.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook deleted.")
+
+	if webhook.OrgID != 0 {
+		http.Redirect(w, r, fmt.Sprintf("/orgs/%d/webhooks", webhook.OrgID), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// webhookRedeliverPost sends the payload of a delivery again, as a new delivery signed
+// with the current secret of the webhook.
+func (app *application) webhookRedeliverPost(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("delivery"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	delivery, err := app.webhooks.Delivery(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	// The delivery must be one of this webhook, which the user was allowed to manage
+	if delivery.WebhookID != webhook.ID {
+		app.notFound(w, r)
+		return
+	}
+
+	deliveryID, err := app.webhooks.InsertDelivery(r.Context(), webhook.ID, delivery.Event, delivery.Payload)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		app.deliver(webhook, deliveryID, delivery.Event, []byte(delivery.Payload))
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Delivery #%d is being sent again as #%d.", delivery.ID, deliveryID))
+
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/%d", webhook.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,683 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// roundTripFunc answers the requests of an http.Client without a network.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func TestSignPayload(t *testing.T) {
+	got := signPayload("secret", []byte(`{"event":"snippet.created"}`))
+
+	assert.Equal(t, got, "sha256=067ca9dc5f4a28861688510200f89aa0162bf0001bb6e910f7113e8142a1b630")
+}
+
+func TestWebhooksPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Own webhooks",
+			email:    "alice@example.com",
+			urlPath:  "/account/webhooks",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/webhooks/1'>https://chat.example.com/hooks/alice</a>",
+		},
+		{
+			name:     "Owner of the organization",
+			email:    "alice@example.com",
+			urlPath:  "/orgs/1/webhooks",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/webhooks/2'>https://ci.example.com/hooks/club</a>",
+		},
+		{
+			name:     "Member of the organization",
+			email:    "mod@example.com",
+			urlPath:  "/orgs/1/webhooks",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Outsider of the organization",
+			email:    "admin@example.com",
+			urlPath:  "/orgs/1/webhooks",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent organization",
+			email:    "alice@example.com",
+			urlPath:  "/orgs/99/webhooks",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWebhookView(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []string
+	}{
+		{
+			name:     "Own webhook",
+			email:    "alice@example.com",
+			urlPath:  "/webhooks/1",
+			wantCode: http.StatusOK,
+			wantBody: []string{
+				"<code class='webhook-secret'>alice-webhook-secret</code>",
+				"<td class='delivery-failed'>503</td>",
+				"<td class='delivery-ok'>204</td>",
+				"<form action='/webhooks/1/deliveries/1/redeliver' method='POST'>",
+			},
+		},
+		{
+			name:     "Webhook of the organization",
+			email:    "alice@example.com",
+			urlPath:  "/webhooks/2",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<a href='/orgs/1'>Haiku Club</a>", "Nothing has been sent to this webhook yet."},
+		},
+		{
+			name:     "Webhook of another user",
+			email:    "mod@example.com",
+			urlPath:  "/webhooks/1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Member of the organization",
+			email:    "mod@example.com",
+			urlPath:  "/webhooks/2",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent webhook",
+			email:    "alice@example.com",
+			urlPath:  "/webhooks/9",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+}
+
+func TestWebhookCreatePost(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		url          string
+		events       []string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid webhook",
+			email:        "alice@example.com",
+			urlPath:      "/account/webhooks",
+			url:          "https://ci.example.com/snippets",
+			events:       []string{models.EventSnippetCreated},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/webhooks/3",
+		},
+		{
+			name:         "Valid webhook of the organization",
+			email:        "alice@example.com",
+			urlPath:      "/orgs/1/webhooks",
+			url:          "http://bot.internal:8080/hook",
+			events:       models.WebhookEvents,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/webhooks/3",
+		},
+		{
+			name:     "Member of the organization",
+			email:    "mod@example.com",
+			urlPath:  "/orgs/1/webhooks",
+			url:      "https://ci.example.com/snippets",
+			events:   []string{models.EventSnippetCreated},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Blank URL",
+			email:    "alice@example.com",
+			urlPath:  "/account/webhooks",
+			events:   []string{models.EventSnippetCreated},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Not a web URL",
+			email:    "alice@example.com",
+			urlPath:  "/account/webhooks",
+			url:      "ftp://ci.example.com/snippets",
+			events:   []string{models.EventSnippetCreated},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "No events",
+			email:    "alice@example.com",
+			urlPath:  "/account/webhooks",
+			url:      "https://ci.example.com/snippets",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Unknown event",
+			email:    "alice@example.com",
+			urlPath:  "/account/webhooks",
+			url:      "https://ci.example.com/snippets",
+			events:   []string{"snippet.commented"},
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/account/webhooks")
+
+			form := url.Values{}
+			form.Add("url", tt.url)
+			for _, event := range tt.events {
+				form.Add("events", event)
+			}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestWebhookDeletePost(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Own webhook",
+			email:        "alice@example.com",
+			urlPath:      "/webhooks/1/delete",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/webhooks",
+		},
+		{
+			name:         "Webhook of the organization",
+			email:        "alice@example.com",
+			urlPath:      "/webhooks/2/delete",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/orgs/1/webhooks",
+		},
+		{
+			name:     "Webhook of another user",
+			email:    "mod@example.com",
+			urlPath:  "/webhooks/1/delete",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			_, _, body := ts.get(t, "/account/webhooks")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestWebhookRedeliverPost(t *testing.T) {
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Delivery of the webhook",
+			urlPath:      "/webhooks/1/deliveries/1/redeliver",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/webhooks/1",
+		},
+		{
+			name:     "Delivery of another webhook",
+			urlPath:  "/webhooks/2/deliveries/1/redeliver",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent delivery",
+			urlPath:  "/webhooks/1/deliveries/9/redeliver",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			// The mock webhooks point at addresses that don't exist
+			var sent string
+			app.webhookSender.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(r.Body)
+				sent = string(body)
+				return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+			})
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, "alice@example.com", "pa$$word")
+
+			_, _, body := ts.get(t, "/webhooks/1")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			app.wg.Wait()
+
+			if tt.wantCode == http.StatusSeeOther {
+				// The payload goes out again as it was
+				assert.Equal(t, sent, `{"event":"snippet.created"}`)
+			}
+		})
+	}
+}
+
+// receivedHook is a request that a webhook receiver got.
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+// newHookReceiver starts a server that answers the deliveries it gets with the given
+// status codes in turn, the last one over and over.
+func newHookReceiver(t *testing.T, statusCodes ...int) (*httptest.Server, func() []receivedHook) {
+	var mu sync.Mutex
+	var received []receivedHook
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		received = append(received, receivedHook{header: r.Header, body: body})
+		w.WriteHeader(statusCodes[min(len(received), len(statusCodes))-1])
+	}))
+
+	return srv, func() []receivedHook {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return received
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	app.webhookSender.backoff = time.Millisecond
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The receiver fails once, then takes the deliveries
+	receiver, received := newHookReceiver(t, http.StatusServiceUnavailable, http.StatusNoContent)
+	defer receiver.Close()
+
+	ctx := context.Background()
+
+	assert.NilError(t, app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"))
+	ts.login(t, "alice@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/account/webhooks")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("url", receiver.URL+"/hook")
+	form.Add("events", models.EventSnippetCreated)
+	form.Add("events", models.EventSnippetUpdated)
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	webhookPath := headers.Get("Location")
+
+	_, _, body = ts.get(t, webhookPath)
+	secret := regexp.MustCompile(`<code class='webhook-secret'>([0-9a-f]+)</code>`).FindStringSubmatch(body)
+	if secret == nil {
+		t.Fatalf("no secret in %q", body)
+	}
+
+	form = url.Values{}
+	form.Add("title", "Deploy")
+	form.Add("content", "make deploy")
+	form.Add("content_type", "text")
+	form.Add("expires", "7")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetPath := headers.Get("Location")
+
+	app.wg.Wait()
+
+	// The first attempt failed, the retry went through
+	hooks := received()
+	assert.Equal(t, len(hooks), 2)
+	assert.Equal(t, string(hooks[1].body), string(hooks[0].body))
+
+	hook := hooks[1]
+	assert.Equal(t, hook.header.Get("Content-Type"), "application/json")
+	assert.Equal(t, hook.header.Get("X-Snippetbox-Event"), models.EventSnippetCreated)
+	assert.Equal(t, hook.header.Get("X-Snippetbox-Delivery"), "1")
+	assert.Equal(t, hook.header.Get("X-Snippetbox-Signature-256"), signPayload(secret[1], hook.body))
+
+	var payload webhookPayload
+	assert.NilError(t, json.Unmarshal(hook.body, &payload))
+	assert.Equal(t, payload.Event, models.EventSnippetCreated)
+	assert.Equal(t, payload.Snippet.Title, "Deploy")
+	assert.Equal(t, payload.URL, ts.URL+snippetPath)
+	assert.Equal(t, payload.Actor.Name, "Alice")
+
+	_, _, body = ts.get(t, webhookPath)
+	assert.StringContains(t, body, "<td class='delivery-ok'>204</td>\n<td>2</td>")
+
+	form = url.Values{}
+	form.Add("title", "Deploy to production")
+	form.Add("content", "make deploy ENV=production")
+	form.Add("content_type", "text")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, snippetPath+"/edit", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	app.wg.Wait()
+
+	hooks = received()
+	assert.Equal(t, len(hooks), 3)
+	assert.Equal(t, hooks[2].header.Get("X-Snippetbox-Event"), models.EventSnippetUpdated)
+
+	assert.NilError(t, json.Unmarshal(hooks[2].body, &payload))
+	assert.Equal(t, payload.Snippet.Title, "Deploy to production")
+
+	// A redelivery sends the same payload as a new delivery
+	code, _, _ = ts.postForm(t, webhookPath+"/deliveries/1/redeliver", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	app.wg.Wait()
+
+	hooks = received()
+	assert.Equal(t, len(hooks), 4)
+	assert.Equal(t, string(hooks[3].body), string(hooks[0].body))
+	assert.Equal(t, hooks[3].header.Get("X-Snippetbox-Delivery"), "3")
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		stop         bool
+		wantAttempts int
+	}{
+		{
+			name:         "Server errors until the last attempt",
+			statusCodes:  []int{http.StatusBadGateway},
+			wantAttempts: 5,
+		},
+		{
+			name:         "Too many requests",
+			statusCodes:  []int{http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 2,
+		},
+		{
+			name:         "Client error",
+			statusCodes:  []int{http.StatusGone},
+			wantAttempts: 1,
+		},
+		{
+			name:         "Redirect",
+			statusCodes:  []int{http.StatusFound},
+			wantAttempts: 1,
+		},
+		{
+			name:         "Stopped sender",
+			statusCodes:  []int{http.StatusServiceUnavailable},
+			stop:         true,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newMemoryTestApplication(t)
+			app.webhookSender.backoff = time.Millisecond
+
+			if tt.stop {
+				// Like during shutdown, where the retries would otherwise wait for an hour
+				app.webhookSender.backoff = time.Hour
+				app.webhookSender.Stop()
+			}
+
+			receiver, received := newHookReceiver(t, tt.statusCodes...)
+			defer receiver.Close()
+
+			ctx := context.Background()
+
+			assert.NilError(t, app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"))
+
+			id, err := app.webhooks.Insert(ctx, 1, 0, receiver.URL, models.WebhookEvents)
+			assert.NilError(t, err)
+
+			webhook, err := app.webhooks.Get(ctx, id)
+			assert.NilError(t, err)
+
+			deliveryID, err := app.webhooks.InsertDelivery(ctx, id, models.EventSnippetCreated, "{}")
+			assert.NilError(t, err)
+
+			app.deliver(webhook, deliveryID, models.EventSnippetCreated, []byte("{}"))
+
+			assert.Equal(t, len(received()), tt.wantAttempts)
+
+			delivery, err := app.webhooks.Delivery(ctx, deliveryID)
+			assert.NilError(t, err)
+			assert.Equal(t, delivery.Attempts, tt.wantAttempts)
+			assert.Equal(t, delivery.StatusCode, tt.statusCodes[min(tt.wantAttempts, len(tt.statusCodes))-1])
+		})
+	}
+}
+
+func TestWebhookAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.215.14:443"},
+		{address: "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443"},
+		{address: "127.0.0.1:8080", wantErr: true},
+		{address: "10.0.0.5:80", wantErr: true},
+		{address: "192.168.1.1:80", wantErr: true},
+		// The metadata service of the cloud providers
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "0.0.0.0:80", wantErr: true},
+		{address: "[::1]:80", wantErr: true},
+		{address: "[::ffff:127.0.0.1]:80", wantErr: true},
+		{address: "[fd00::1]:80", wantErr: true},
+		{address: "[fe80::1]:80", wantErr: true},
+	}
+
+	s := newWebhookSender()
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := s.checkAddress("tcp", tt.address, nil)
+			assert.Equal(t, errors.Is(err, errPrivateAddress), tt.wantErr)
+		})
+	}
+}
+
+func TestDeliverPrivateAddress(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	app.webhookSender.allowPrivate = false
+
+	receiver, received := newHookReceiver(t, http.StatusOK)
+	defer receiver.Close()
+
+	ctx := context.Background()
+
+	assert.NilError(t, app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"))
+
+	// The receiver listens on 127.0.0.1, like a service on the server itself would
+	id, err := app.webhooks.Insert(ctx, 1, 0, receiver.URL, models.WebhookEvents)
+	assert.NilError(t, err)
+
+	webhook, err := app.webhooks.Get(ctx, id)
+	assert.NilError(t, err)
+
+	deliveryID, err := app.webhooks.InsertDelivery(ctx, id, models.EventSnippetCreated, "{}")
+	assert.NilError(t, err)
+
+	app.deliver(webhook, deliveryID, models.EventSnippetCreated, []byte("{}"))
+
+	assert.Equal(t, len(received()), 0)
+
+	// It is refused once, without waiting for retries
+	delivery, err := app.webhooks.Delivery(ctx, deliveryID)
+	assert.NilError(t, err)
+	assert.Equal(t, delivery.Attempts, 1)
+	assert.Equal(t, delivery.StatusCode, 0)
+	assert.StringContains(t, delivery.Error, errPrivateAddress.Error())
+}
+
+func TestWebhookDeletedEvent(t *testing.T) {
+	app := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	receiver, received := newHookReceiver(t, http.StatusOK)
+	defer receiver.Close()
+
+	ctx := context.Background()
+
+	assert.NilError(t, app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"))
+	assert.NilError(t, app.users.Insert(ctx, "Adam", "admin@example.com", "validPa$$word"))
+	assert.NilError(t, app.users.SetRole(ctx, 2, models.RoleAdmin))
+
+	// The snippet of an organization goes to its webhooks, not to those of its creator
+	orgID, err := app.organizations.Insert(ctx, "Ops", 1)
+	assert.NilError(t, err)
+
+	_, err = app.webhooks.Insert(ctx, 0, orgID, receiver.URL, []string{models.EventSnippetDeleted})
+	assert.NilError(t, err)
+	_, err = app.webhooks.Insert(ctx, 1, 0, receiver.URL, models.WebhookEvents)
+	assert.NilError(t, err)
+
+	_, slug, err := app.snippets.Insert(ctx, 1, orgID, "Deploy", "make deploy", models.ContentTypeText, 7)
+	assert.NilError(t, err)
+
+	ts.login(t, "admin@example.com", "validPa$$word")
+
+	_, _, body := ts.get(t, "/admin/snippets")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/admin/snippets/1/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	app.wg.Wait()
+
+	hooks := received()
+	assert.Equal(t, len(hooks), 1)
+	assert.Equal(t, hooks[0].header.Get("X-Snippetbox-Event"), models.EventSnippetDeleted)
+
+	// The payload has the snippet as it was
+	var payload webhookPayload
+	assert.NilError(t, json.Unmarshal(hooks[0].body, &payload))
+	assert.Equal(t, payload.Snippet.Slug, slug)
+	assert.Equal(t, payload.Snippet.Title, "Deploy")
+	assert.Equal(t, payload.Actor.Name, "Adam")
+}
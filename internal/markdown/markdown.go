@@ -0,0 +1,74 @@
+// Package markdown renders the markdown snippets to HTML: CommonMark, which has fenced
+// code blocks, plus the tables of GitHub Flavored Markdown. Raw HTML in the source is
+// dropped by the renderer, and the output goes through an allow-list sanitizer anyway,
+// so what Render returns is safe to put in a page as it is.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// linkRel is set on every link, snippets are user content that we don't vouch for.
+const linkRel = "nofollow noopener"
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.Table),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(linkTransformer{}, 100)),
+	),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	// The language of a fenced code block, for client side highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	// Table cells are aligned with a style attribute
+	p.AllowAttrs("style").Matching(regexp.MustCompile(`^text-align:(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^` + linkRel + `$`)).OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+
+	return p
+}
+
+// linkTransformer adds the rel attribute to the links and autolinks of a document.
+type linkTransformer struct{}
+
+func (linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n.Kind() {
+		case ast.KindLink, ast.KindAutoLink:
+			n.SetAttributeString("rel", []byte(linkRel))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// Render converts the markdown in src to sanitized HTML.
+func Render(src string) (template.HTML, error) {
+	var buf bytes.Buffer
+
+	err := md.Convert([]byte(src), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(policy.SanitizeReader(&buf).String()), nil
+}
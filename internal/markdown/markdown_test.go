@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "CommonMark",
+			src:  "# An old pond\n\nA *frog* jumps in.",
+			want: []string{"<h1>An old pond</h1>", "<p>A <em>frog</em> jumps in.</p>"},
+		},
+		{
+			name: "Fenced code",
+			src:  "```go\nfmt.Println(\"<splash>\")\n```",
+			want: []string{`<pre><code class="language-go">fmt.Println(&#34;&lt;splash&gt;&#34;)`},
+		},
+		{
+			name: "Table",
+			src:  "| Season | Word |\n| :--- | ---: |\n| Spring | frog |",
+			want: []string{"<table>", `<th style="text-align:left">Season</th>`, `<td style="text-align:right">frog</td>`},
+		},
+		{
+			name: "Links",
+			src:  "[pond](https://example.com/pond) and <https://example.com/frog>",
+			want: []string{
+				`<a href="https://example.com/pond" rel="nofollow noopener">pond</a>`,
+				`<a href="https://example.com/frog" rel="nofollow noopener">https://example.com/frog</a>`,
+			},
+		},
+		{
+			name:    "Raw HTML",
+			src:     "<script>alert(1)</script>\n\n<a href=\"/x\" onclick=\"alert(1)\">x</a>",
+			notWant: []string{"<script", "onclick", "alert"},
+		},
+		{
+			name:    "JavaScript link",
+			src:     "[click](javascript:alert(1))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "Image event handler",
+			src:     "![frog](https://example.com/frog.png \"x\\\" onerror=\\\"alert(1)\")",
+			notWant: []string{"onerror="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.src)
+			assert.NilError(t, err)
+
+			for _, want := range tt.want {
+				assert.StringContains(t, string(got), want)
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(got), notWant) {
+					t.Errorf("got: %q; expected no %q", got, notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,437 @@
+// Package migrate applies numbered SQL migrations to a PostgreSQL or SQLite database.
+// The applied versions are recorded in the schema_migrations table. On PostgreSQL
+// every run holds an advisory lock so that only one instance migrates the database at
+// a time, SQLite relies on its own write lock taken by each migration transaction.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// lockID is the key of the session level advisory lock held while migrating. Any
+// constant works as long as nothing else in the database uses it.
+const lockID = 748_302_116
+
+var (
+	ErrUnknownVersion = errors.New("migrate: unknown version")
+	ErrNotEmpty       = errors.New("migrate: migrations have already been applied, a baseline is only for a database that has none")
+)
+
+// Dialect is the kind of database the migrations are written for.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, Applied is zero if it hasn't been.
+type Status struct {
+	Migration
+	Applied time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []Migration
+	// Logger gets a line for every migration applied or rolled back, it may be nil
+	Logger *slog.Logger
+}
+
+// fileRX matches the migration file names, like 0001_create_snippets.up.sql.
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// New reads the migrations for dialect from the top of fsys. Every version needs both
+// an up and a down file.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if dialect != Postgres && dialect != SQLite {
+		return nil, fmt.Errorf("migrate: unknown dialect %q", dialect)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: %s: invalid version", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) needs both an up and a down file", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// Latest returns the version of the last migration, or 0 if there are none.
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Up applies every migration that hasn't been applied yet.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration, if any.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			return nil
+		}
+
+		versions := sortedVersions(applied)
+
+		return m.rollback(ctx, conn, versions[len(versions)-1])
+	})
+}
+
+// To migrates the database up or down so that exactly the migrations up to and
+// including version are applied. Version 0 rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && !slices.ContainsFunc(m.Migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Roll back newest first, then apply oldest first
+		versions := sortedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > version; i-- {
+			err = m.rollback(ctx, conn, versions[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, mig := range m.Migrations {
+			if mig.Version > version {
+				break
+			}
+
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err = m.apply(ctx, conn, mig)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Baseline records the migrations up to and including version as applied, without
+// running them. It adopts a database whose schema was made by hand up to that version,
+// as the readme once had it done, so that Up only applies what comes after. It returns
+// ErrNotEmpty if any migration is already recorded.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	if !slices.ContainsFunc(m.Migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) > 0 {
+			return ErrNotEmpty
+		}
+
+		return inTx(ctx, conn, func(tx *sql.Tx) error {
+			for _, mig := range m.Migrations {
+				if mig.Version > version {
+					break
+				}
+
+				m.logger().Info("recording migration as applied", "version", mig.Version, "name", mig.Name)
+
+				_, err = tx.ExecContext(ctx, m.rebind(`INSERT INTO schema_migrations (version, name, applied)
+				VALUES ($1, $2, $3)`), mig.Version, mig.Name, time.Now().UTC())
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// Status lists every known migration along with when it was applied. Versions
+// applied to the database that aren't known here are listed too, with no SQL.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			statuses = append(statuses, Status{Migration: mig, Applied: applied[mig.Version].applied})
+			delete(applied, mig.Version)
+		}
+
+		for _, version := range sortedVersions(applied) {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: version, Name: applied[version].name},
+				Applied:   applied[version].applied,
+			})
+		}
+
+		slices.SortFunc(statuses, func(a, b Status) int { return a.Version - b.Version })
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection holding the advisory lock, after making
+// sure the schema_migrations table exists. The lock belongs to the connection, which
+// is why everything has to go through the same one. SQLite has no advisory locks,
+// there apply and rollback check the version again once their transaction holds the
+// write lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Dialect == Postgres {
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+		if err != nil {
+			return fmt.Errorf("migrate: acquiring lock: %w", err)
+		}
+
+		defer func() {
+			// The context may be done by now, and the lock must still be released
+			conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied timestamp NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+type appliedVersion struct {
+	name    string
+	applied time.Time
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]appliedVersion, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedVersion{}
+
+	for rows.Next() {
+		var version int
+		var av appliedVersion
+
+		err = rows.Scan(&version, &av.name, &av.applied)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = av
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+func sortedVersions(applied map[int]appliedVersion) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+
+	return versions
+}
+
+// apply runs the up script of mig and records it in one transaction, so a failed
+// migration leaves nothing behind.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	m.logger().Info("applying migration", "version", mig.Version, "name", mig.Name)
+
+	return inTx(ctx, conn, func(tx *sql.Tx) error {
+		applied, err := m.isApplied(ctx, tx, mig.Version)
+		if err != nil || applied {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, mig.Up)
+		if err != nil {
+			return fmt.Errorf("migrate: version %d (%s) up: %w", mig.Version, mig.Name, err)
+		}
+
+		_, err = tx.ExecContext(ctx, m.rebind(`INSERT INTO schema_migrations (version, name, applied)
+		VALUES ($1, $2, $3)`), mig.Version, mig.Name, time.Now().UTC())
+
+		return err
+	})
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, version int) error {
+	i := slices.IndexFunc(m.Migrations, func(mig Migration) bool { return mig.Version == version })
+	if i < 0 {
+		return fmt.Errorf("%w %d: it is applied to the database but there is no migration to roll it back", ErrUnknownVersion, version)
+	}
+
+	mig := m.Migrations[i]
+	m.logger().Info("rolling back migration", "version", mig.Version, "name", mig.Name)
+
+	return inTx(ctx, conn, func(tx *sql.Tx) error {
+		applied, err := m.isApplied(ctx, tx, mig.Version)
+		if err != nil || !applied {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, mig.Down)
+		if err != nil {
+			return fmt.Errorf("migrate: version %d (%s) down: %w", mig.Version, mig.Name, err)
+		}
+
+		_, err = tx.ExecContext(ctx, m.rebind(`DELETE FROM schema_migrations WHERE version = $1`), mig.Version)
+
+		return err
+	})
+}
+
+func (m *Migrator) isApplied(ctx context.Context, tx *sql.Tx, version int) (bool, error) {
+	var applied bool
+
+	err := tx.QueryRowContext(ctx, m.rebind(`SELECT EXISTS(SELECT true FROM schema_migrations WHERE version = $1)`), version).Scan(&applied)
+
+	return applied, err
+}
+
+// placeholderRX matches the numbered placeholders of PostgreSQL, like $1.
+var placeholderRX = regexp.MustCompile(`\$\d+`)
+
+// rebind turns the placeholders of a query written for PostgreSQL into the ones of
+// the dialect. It only works for queries using each argument once, in order.
+func (m *Migrator) rebind(query string) string {
+	if m.Dialect == SQLite {
+		return placeholderRX.ReplaceAllString(query, "?")
+	}
+
+	return query
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) logger() *slog.Logger {
+	if m.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return m.Logger
+}
@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/migrations"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("Embedded migrations", func(t *testing.T) {
+		var postgres, sqlite *Migrator
+
+		for _, dialect := range []Dialect{Postgres, SQLite} {
+			files, err := fs.Sub(migrations.Files, string(dialect))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := New(nil, dialect, files)
+			assert.NilError(t, err)
+
+			// Versions are numbered from 1 without gaps
+			for i, mig := range m.Migrations {
+				assert.Equal(t, mig.Version, i+1)
+			}
+			assert.Equal(t, m.Latest(), len(m.Migrations))
+
+			if dialect == Postgres {
+				postgres = m
+			} else {
+				sqlite = m
+			}
+		}
+
+		// Both backends go through the same schema versions
+		assert.Equal(t, len(sqlite.Migrations), len(postgres.Migrations))
+		for i := range postgres.Migrations {
+			assert.Equal(t, sqlite.Migrations[i].Name, postgres.Migrations[i].Name)
+		}
+	})
+
+	t.Run("Sorted by version", func(t *testing.T) {
+		m, err := New(nil, Postgres, fstest.MapFS{
+			"0010_second.up.sql":   {Data: []byte("up 10")},
+			"0010_second.down.sql": {Data: []byte("down 10")},
+			"0002_first.up.sql":    {Data: []byte("up 2")},
+			"0002_first.down.sql":  {Data: []byte("down 2")},
+			"README.md":            {Data: []byte("ignored")},
+		})
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(m.Migrations), 2)
+		assert.Equal(t, m.Migrations[0].Name, "first")
+		assert.Equal(t, m.Migrations[0].Down, "down 2")
+		assert.Equal(t, m.Migrations[1].Version, 10)
+		assert.Equal(t, m.Latest(), 10)
+	})
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Missing down",
+			files: fstest.MapFS{"0001_first.up.sql": {Data: []byte("up")}},
+		},
+		{
+			name: "Duplicate version",
+			files: fstest.MapFS{
+				"0001_first.up.sql":    {Data: []byte("up")},
+				"0001_first.down.sql":  {Data: []byte("down")},
+				"0001_second.up.sql":   {Data: []byte("up")},
+				"0001_second.down.sql": {Data: []byte("down")},
+			},
+		},
+		{
+			name: "Version zero",
+			files: fstest.MapFS{
+				"0000_first.up.sql":   {Data: []byte("up")},
+				"0000_first.down.sql": {Data: []byte("down")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(nil, Postgres, tt.files)
+			if err == nil {
+				t.Error("got: nil; expected an error")
+			}
+		})
+	}
+}
+
+// TestMigrator runs on SQLite only. The PostgreSQL migrations are applied and rolled
+// back by every model test, which share the test_snippetbox database with nothing else.
+func TestMigrator(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_foreign_keys=on&_txlock=immediate"
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	files, err := fs.Sub(migrations.Files, string(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(db, SQLite, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testMigrator(t, m)
+}
+
+func testMigrator(t *testing.T, m *Migrator) {
+	ctx := context.Background()
+
+	applied := func() int {
+		t.Helper()
+
+		statuses, err := m.Status(ctx)
+		assert.NilError(t, err)
+
+		n := 0
+		for _, s := range statuses {
+			if !s.Applied.IsZero() {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	assert.NilError(t, m.Up(ctx))
+	assert.Equal(t, applied(), m.Latest())
+
+	// Up again is a no-op
+	assert.NilError(t, m.Up(ctx))
+
+	assert.NilError(t, m.Down(ctx))
+	assert.Equal(t, applied(), m.Latest()-1)
+
+	assert.NilError(t, m.To(ctx, 1))
+	assert.Equal(t, applied(), 1)
+
+	err := m.To(ctx, m.Latest()+1)
+	assert.Equal(t, errors.Is(err, ErrUnknownVersion), true)
+
+	// Every down script works, and the up scripts still apply after them
+	assert.NilError(t, m.To(ctx, 0))
+	assert.Equal(t, applied(), 0)
+
+	assert.NilError(t, m.Up(ctx))
+	assert.NilError(t, m.To(ctx, 0))
+
+	// A schema made by hand up to version 1 is adopted without running it again
+	_, err = m.DB.ExecContext(ctx, m.Migrations[0].Up)
+	assert.NilError(t, err)
+
+	err = m.Baseline(ctx, m.Latest()+1)
+	assert.Equal(t, errors.Is(err, ErrUnknownVersion), true)
+
+	assert.NilError(t, m.Baseline(ctx, 1))
+	assert.Equal(t, applied(), 1)
+
+	err = m.Baseline(ctx, 1)
+	assert.Equal(t, errors.Is(err, ErrNotEmpty), true)
+
+	assert.NilError(t, m.Up(ctx))
+	assert.Equal(t, applied(), m.Latest())
+	assert.NilError(t, m.To(ctx, 0))
+}
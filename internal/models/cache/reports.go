@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// ReportModel passes the reports through to the model it wraps, and drops a snippet
+// from the cache once a moderator has hidden, restored or deleted it.
+type ReportModel struct {
+	models.ReportModelInterface
+	Snippets *SnippetModel
+}
+
+func (m *ReportModel) Moderate(ctx context.Context, snippetID, moderatorID int, action string) error {
+	err := m.ReportModelInterface.Moderate(ctx, snippetID, moderatorID, action)
+	if err != nil {
+		return err
+	}
+
+	m.Snippets.Invalidate(snippetID)
+	return nil
+}
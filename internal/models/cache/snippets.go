@@ -0,0 +1,337 @@
+// Package cache keeps the snippets read on every page view in process memory, in front
+// of another snippet model. Each instance of the application has its own cache, so a
+// write made through one instance is only seen by the others once their entries expire.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+const latestKey = "latest"
+
+func snippetKey(id int) string {
+	return "snippet:" + strconv.Itoa(id)
+}
+
+func slugKey(slug string) string {
+	return "slug:" + slug
+}
+
+// Stats counts the lookups served by the cache and the ones that went to the model
+// behind it. Misses that waited on a query already running for the same key are
+// counted as misses too.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type entry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// SnippetModel is a least recently used cache of Get, GetBySlug and Latest. An entry lives for the
+// TTL, or until its snippet expires if that comes first, and is dropped by any write made
+// through the cache. List, ByUser and ByOrg are read too rarely and with too many variations
+// to be worth caching, so they always go to the model.
+type SnippetModel struct {
+	next models.SnippetModelInterface
+	size int
+	ttl  time.Duration
+
+	mu sync.Mutex
+	// The most recently used entry is at the front
+	order   *list.List
+	entries map[string]*list.Element
+	// Bumped by every invalidation, so that a query started before it doesn't store
+	// what it read once it returns
+	generation uint64
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewSnippetModel returns a cache of up to size entries in front of next.
+func NewSnippetModel(next models.SnippetModelInterface, size int, ttl time.Duration) *SnippetModel {
+	return &SnippetModel{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error) {
+	id, slug, err := m.next.Insert(ctx, userID, orgID, title, content, contentType, expires)
+	if err != nil {
+		return 0, "", err
+	}
+
+	m.invalidate(latestKey)
+	return id, slug, nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, contentType string) error {
+	err := m.next.Update(ctx, id, title, content, contentType)
+	if err != nil {
+		return err
+	}
+
+	m.Invalidate(id)
+	return nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	v, err := m.load(ctx, snippetKey(id), func(ctx context.Context) (any, time.Time, error) {
+		s, err := m.next.Get(ctx, id)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return s, s.Expires, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every caller gets its own copy, the cached one is shared
+	s := *v.(*models.Snippet)
+	return &s, nil
+}
+
+// GetLegacy isn't cached, the numeric addresses are only followed by old links.
+func (m *SnippetModel) GetLegacy(ctx context.Context, id int) (*models.Snippet, error) {
+	return m.next.GetLegacy(ctx, id)
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	v, err := m.load(ctx, slugKey(slug), func(ctx context.Context) (any, time.Time, error) {
+		s, err := m.next.GetBySlug(ctx, slug)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return s, s.Expires, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := *v.(*models.Snippet)
+	return &s, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	v, err := m.load(ctx, latestKey, func(ctx context.Context) (any, time.Time, error) {
+		snippets, err := m.next.Latest(ctx)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		// The listing is out of date as soon as the first of its snippets expires
+		var expires time.Time
+		for _, s := range snippets {
+			if expires.IsZero() || s.Expires.Before(expires) {
+				expires = s.Expires
+			}
+		}
+
+		return snippets, expires, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cached := v.([]*models.Snippet)
+	snippets := make([]*models.Snippet, len(cached))
+	for i, s := range cached {
+		c := *s
+		snippets[i] = &c
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, limit int) ([]*models.Snippet, error) {
+	return m.next.ByUser(ctx, userID, limit)
+}
+
+func (m *SnippetModel) ByOrg(ctx context.Context, orgID int, limit int) ([]*models.Snippet, error) {
+	return m.next.ByOrg(ctx, orgID, limit)
+}
+
+func (m *SnippetModel) List(ctx context.Context, search string, limit, offset int) ([]*models.Snippet, int, error) {
+	return m.next.List(ctx, search, limit, offset)
+}
+
+func (m *SnippetModel) After(ctx context.Context, afterID, limit int) ([]*models.Snippet, error) {
+	return m.next.After(ctx, afterID, limit)
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	err := m.next.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	m.Invalidate(id)
+	return nil
+}
+
+// DeleteExpired leaves the cache alone, no entry outlives the snippets it holds.
+func (m *SnippetModel) DeleteExpired(ctx context.Context) (int, error) {
+	return m.next.DeleteExpired(ctx)
+}
+
+// Invalidate drops a snippet and the latest snippets, for the writes that reach the
+// snippets without going through the cache, like moderation.
+func (m *SnippetModel) Invalidate(id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := []string{snippetKey(id), latestKey}
+
+	// A snippet read by its slug is cached under the slug, which only the entry knows
+	for key, el := range m.entries {
+		if s, ok := el.Value.(*entry).value.(*models.Snippet); ok && s.ID == id {
+			keys = append(keys, key)
+		}
+	}
+
+	m.invalidateLocked(keys...)
+}
+
+// Stats returns the hit and miss counts since the cache was created.
+func (m *SnippetModel) Stats() Stats {
+	m.mu.Lock()
+	entries := m.order.Len()
+	m.mu.Unlock()
+
+	return Stats{
+		Hits:    m.hits.Load(),
+		Misses:  m.misses.Load(),
+		Entries: entries,
+	}
+}
+
+// load returns the cached value of key, or runs fetch to read it from the model. Concurrent
+// misses of the same key share a single fetch, which isn't canceled when one of the callers
+// goes away, but each caller still stops waiting when its own context is done. Errors,
+// including models.ErrNoRecord, are never cached.
+func (m *SnippetModel) load(ctx context.Context, key string, fetch func(ctx context.Context) (any, time.Time, error)) (any, error) {
+	v, ok := m.get(key)
+	if ok {
+		m.hits.Add(1)
+		return v, nil
+	}
+
+	m.misses.Add(1)
+
+	ch := m.group.DoChan(key, func() (any, error) {
+		m.mu.Lock()
+		generation := m.generation
+		m.mu.Unlock()
+
+		// The model applies its own query timeout
+		v, expires, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		m.set(key, v, expires, generation)
+		return v, nil
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, models.ContextError(ctx, ctx.Err())
+	}
+}
+
+func (m *SnippetModel) get(key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !time.Now().Before(e.expires) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	m.order.MoveToFront(el)
+	return e.value, true
+}
+
+// set stores v until the TTL or expires, whichever comes first, and evicts the least
+// recently used entry when the cache is full. Nothing is stored if the cache was
+// invalidated since generation.
+func (m *SnippetModel) set(key string, v any, expires time.Time, generation uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if generation != m.generation {
+		return
+	}
+
+	deadline := time.Now().Add(m.ttl)
+	if !expires.IsZero() && expires.Before(deadline) {
+		deadline = expires
+	}
+
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value = v
+		e.expires = deadline
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&entry{key: key, value: v, expires: deadline})
+
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*entry).key)
+	}
+}
+
+func (m *SnippetModel) invalidate(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.invalidateLocked(keys...)
+}
+
+// invalidateLocked drops keys from the cache. The caller must hold the lock.
+func (m *SnippetModel) invalidateLocked(keys ...string) {
+	m.generation++
+
+	for _, key := range keys {
+		if el, ok := m.entries[key]; ok {
+			m.order.Remove(el)
+			delete(m.entries, key)
+		}
+
+		// Callers arriving from now on start a new query instead of waiting on one that
+		// may have read the old value
+		m.group.Forget(key)
+	}
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/models/memory"
+)
+
+// countingModel counts the reads that reach the model, and holds them until release
+// is closed when it is set.
+type countingModel struct {
+	models.SnippetModelInterface
+	gets    atomic.Int32
+	latests atomic.Int32
+	release chan struct{}
+	// Overrides the expiry of the snippets returned by Get when set
+	expires time.Time
+}
+
+func (m *countingModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	m.gets.Add(1)
+	if m.release != nil {
+		<-m.release
+	}
+
+	s, err := m.SnippetModelInterface.Get(ctx, id)
+	if err == nil && !m.expires.IsZero() {
+		s.Expires = m.expires
+	}
+
+	return s, err
+}
+
+func (m *countingModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	m.latests.Add(1)
+	return m.SnippetModelInterface.Latest(ctx)
+}
+
+func newTestCache(t *testing.T, size int) (*SnippetModel, *countingModel) {
+	t.Helper()
+
+	db := memory.New()
+	next := &countingModel{SnippetModelInterface: &memory.SnippetModel{DB: db}}
+
+	for _, title := range []string{"An old silent pond", "Over the wintry forest", "First autumn morning"} {
+		_, _, err := next.Insert(context.Background(), 1, 0, title, title+"...", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+	}
+
+	return NewSnippetModel(next, size, time.Minute), next
+}
+
+func TestSnippetCacheHit(t *testing.T) {
+	ctx := context.Background()
+	c, next := newTestCache(t, 10)
+
+	for range 3 {
+		s, err := c.Get(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "An old silent pond")
+
+		// Callers must not be able to change the cached snippet
+		s.Title = "Changed"
+	}
+
+	assert.Equal(t, next.gets.Load(), int32(1))
+	assert.Equal(t, c.Stats(), Stats{Hits: 2, Misses: 1, Entries: 1})
+
+	// Missing snippets are not cached
+	for range 2 {
+		_, err := c.Get(ctx, 4)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	}
+
+	assert.Equal(t, next.gets.Load(), int32(3))
+}
+
+func TestSnippetCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c, next := newTestCache(t, 2)
+
+	for _, id := range []int{1, 2, 1, 3} {
+		_, err := c.Get(ctx, id)
+		assert.NilError(t, err)
+	}
+
+	// 2 was the least recently used when 3 came in
+	assert.Equal(t, next.gets.Load(), int32(3))
+	assert.Equal(t, c.Stats().Entries, 2)
+
+	_, err := c.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, next.gets.Load(), int32(3))
+
+	_, err = c.Get(ctx, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, next.gets.Load(), int32(4))
+}
+
+func TestSnippetCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	c, next := newTestCache(t, 10)
+
+	// The snippet expires well before the TTL, so that is when the entry goes
+	next.expires = time.Now().Add(50 * time.Millisecond)
+
+	_, err := c.Get(ctx, 1)
+	assert.NilError(t, err)
+	_, err = c.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, next.gets.Load(), int32(1))
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = c.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, next.gets.Load(), int32(2))
+}
+
+func TestSnippetCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	c, next := newTestCache(t, 10)
+
+	latest, err := c.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 3)
+
+	_, _, err = c.Insert(ctx, 1, 0, "The crow has flown away", "The crow has flown away...", models.ContentTypeText, 7)
+	assert.NilError(t, err)
+
+	latest, err = c.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 4)
+	assert.Equal(t, latest[0].Title, "The crow has flown away")
+	assert.Equal(t, next.latests.Load(), int32(2))
+
+	_, err = c.Get(ctx, 2)
+	assert.NilError(t, err)
+
+	err = c.Delete(ctx, 2)
+	assert.NilError(t, err)
+
+	_, err = c.Get(ctx, 2)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, err = c.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 3)
+}
+
+func TestSnippetCacheModeration(t *testing.T) {
+	ctx := context.Background()
+
+	db := memory.New()
+	snippets := NewSnippetModel(&memory.SnippetModel{DB: db}, 10, time.Minute)
+	reports := &ReportModel{ReportModelInterface: &memory.ReportModel{DB: db}, Snippets: snippets}
+
+	id, slug, err := snippets.Insert(ctx, 1, 0, "An old silent pond", "An old silent pond...", models.ContentTypeText, 7)
+	assert.NilError(t, err)
+
+	s, err := snippets.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Hidden, false)
+
+	s, err = snippets.GetBySlug(ctx, slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Hidden, false)
+
+	err = reports.Moderate(ctx, id, 1, models.ModerationHide)
+	assert.NilError(t, err)
+
+	s, err = snippets.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Hidden, true)
+
+	// The entry under the slug goes too
+	s, err = snippets.GetBySlug(ctx, slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Hidden, true)
+
+	latest, err := snippets.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 0)
+}
+
+func TestSnippetCacheCollapsedMisses(t *testing.T) {
+	c, next := newTestCache(t, 10)
+	next.release = make(chan struct{})
+
+	const callers = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Get(context.Background(), 1)
+			errs <- err
+		}()
+	}
+
+	// Let every caller miss before the query returns
+	for c.Stats().Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+
+	assert.Equal(t, next.gets.Load(), int32(1))
+}
+
+func TestSnippetCacheCanceledWaiter(t *testing.T) {
+	c, next := newTestCache(t, 10)
+	next.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, 1)
+		done <- err
+	}()
+
+	for c.Stats().Misses < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	err := <-done
+	assert.Equal(t, errors.Is(err, models.ErrCanceled), true)
+
+	// The query kept going and its result was cached for the next caller
+	close(next.release)
+
+	for c.Stats().Entries < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = c.Get(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, next.gets.Load(), int32(1))
+}
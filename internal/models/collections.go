@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type CollectionModelInterface interface {
+	Insert(ctx context.Context, userID int, title, description, visibility string) (int, error)
+	Get(ctx context.Context, id int) (*Collection, error)
+	Update(ctx context.Context, id int, title, description, visibility string) error
+	Delete(ctx context.Context, id int) error
+	ByUser(ctx context.Context, userID int) ([]*Collection, error)
+	BySnippet(ctx context.Context, snippetID int) ([]*Collection, error)
+	Snippets(ctx context.Context, id int) ([]*Snippet, error)
+	AddSnippet(ctx context.Context, id, snippetID int) error
+	RemoveSnippet(ctx context.Context, id, snippetID int) error
+	MoveSnippet(ctx context.Context, id, snippetID int, up bool) error
+}
+
+// Who can see a collection. Public collections are shown to everyone, private ones
+// only to their owner.
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// Collection is a named, ordered group of snippets that belongs to a user.
+type Collection struct {
+	ID          int
+	UserID      int
+	Title       string
+	Description string
+	// VisibilityPublic or VisibilityPrivate
+	Visibility string
+	Created    time.Time
+}
+
+// IsPublic reports whether the collection can be seen by everyone.
+func (c *Collection) IsPublic() bool {
+	return c.Visibility == VisibilityPublic
+}
+
+type CollectionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO collections (user_id, title, description, visibility, created)
+	VALUES ($1, $2, $3, $4, NOW()) RETURNING id`
+
+	var id int
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, title, description, visibility).Scan(&id)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+func (m *CollectionModel) Get(ctx context.Context, id int) (*Collection, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, title, description, visibility, created FROM collections WHERE id = $1`
+
+	c := &Collection{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&c.ID, &c.UserID, &c.Title, &c.Description, &c.Visibility, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, ContextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE collections SET title = $2, description = $3, visibility = $4 WHERE id = $1`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id, title, description, visibility)))
+}
+
+// Delete removes a collection. Its snippets stay, only their place in it is gone.
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM collections WHERE id = $1`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+// ByUser returns the collections of a user, private ones included, by title.
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*Collection, error) {
+	stmt := `SELECT id, user_id, title, description, visibility, created FROM collections
+	WHERE user_id = $1 ORDER BY title, id`
+
+	return m.query(ctx, stmt, userID)
+}
+
+// BySnippet returns the collections that a snippet is in, private ones included, by title.
+func (m *CollectionModel) BySnippet(ctx context.Context, snippetID int) ([]*Collection, error) {
+	stmt := `SELECT c.id, c.user_id, c.title, c.description, c.visibility, c.created
+	FROM collections c JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE cs.snippet_id = $1 ORDER BY c.title, c.id`
+
+	return m.query(ctx, stmt, snippetID)
+}
+
+func (m *CollectionModel) query(ctx context.Context, stmt string, args ...any) ([]*Collection, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		c := &Collection{}
+
+		err = rows.Scan(&c.ID, &c.UserID, &c.Title, &c.Description, &c.Visibility, &c.Created)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return collections, nil
+}
+
+// Snippets returns the snippets of a collection that haven't expired, in their order.
+// Hidden snippets are returned too, it is up to the caller to only show them to those
+// who may see them.
+func (m *CollectionModel) Snippets(ctx context.Context, id int) ([]*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.content_type, s.created, s.updated, s.expires, COALESCE(s.user_id, 0), s.hidden, COALESCE(s.org_id, 0)
+	FROM snippets s JOIN collection_snippets cs ON cs.snippet_id = s.id
+	WHERE cs.collection_id = $1 AND s.expires > now() ORDER BY cs.position`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// AddSnippet puts a snippet at the end of a collection. Adding one that is already in
+// the collection leaves it where it is.
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT $1, $2, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = $1
+	ON CONFLICT DO NOTHING`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, snippetID)
+	if err != nil {
+		var pgSQLError *pq.Error
+		// PostgreSQL foreign key violation error code is "23503".
+		if errors.As(err, &pgSQLError) && pgSQLError.Code == "23503" {
+			return ErrNoRecord
+		}
+		return ContextError(ctx, err)
+	}
+
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = $1 AND snippet_id = $2`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id, snippetID)))
+}
+
+// MoveSnippet swaps a snippet with the one before it in the collection, or the one
+// after it if up is false. Expired snippets aren't shown, so they are skipped. Moving
+// the first snippet up, or the last one down, does nothing.
+func (m *CollectionModel) MoveSnippet(ctx context.Context, id, snippetID int, up bool) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var position int
+
+	stmt := `SELECT position FROM collection_snippets WHERE collection_id = $1 AND snippet_id = $2 FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, stmt, id, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return ContextError(ctx, err)
+	}
+
+	stmt = `SELECT cs.snippet_id, cs.position FROM collection_snippets cs JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = $1 AND cs.position > $2 AND s.expires > now() ORDER BY cs.position LIMIT 1 FOR UPDATE OF cs`
+	if up {
+		stmt = `SELECT cs.snippet_id, cs.position FROM collection_snippets cs JOIN snippets s ON s.id = cs.snippet_id
+		WHERE cs.collection_id = $1 AND cs.position < $2 AND s.expires > now() ORDER BY cs.position DESC LIMIT 1 FOR UPDATE OF cs`
+	}
+
+	var otherID, otherPosition int
+
+	err = tx.QueryRowContext(ctx, stmt, id, position).Scan(&otherID, &otherPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return ContextError(ctx, err)
+	}
+
+	stmt = `UPDATE collection_snippets SET position = $3 WHERE collection_id = $1 AND snippet_id = $2`
+
+	_, err = tx.ExecContext(ctx, stmt, id, snippetID, otherPosition)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, id, otherID, position)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	return ContextError(ctx, tx.Commit())
+}
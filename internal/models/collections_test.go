@@ -0,0 +1,124 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestCollectionModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.Collections
+
+		id, err := m.Insert(ctx, 1, "SQL recipes", "Queries we keep coming back to", models.VisibilityPrivate)
+		assert.NilError(t, err)
+
+		_, err = m.Insert(ctx, 1, "Deploy scripts", "", models.VisibilityPublic)
+		assert.NilError(t, err)
+
+		c, err := m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, c.UserID, 1)
+		assert.Equal(t, c.Title, "SQL recipes")
+		assert.Equal(t, c.Description, "Queries we keep coming back to")
+		assert.Equal(t, c.IsPublic(), false)
+
+		assert.NilError(t, m.Update(ctx, id, "SQL", "", models.VisibilityPublic))
+
+		c, err = m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, c.Title, "SQL")
+		assert.Equal(t, c.IsPublic(), true)
+
+		// Collections are listed by title
+		collections, err := m.ByUser(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(collections), 2)
+		assert.Equal(t, collections[0].Title, "Deploy scripts")
+		assert.Equal(t, collections[1].ID, id)
+
+		first, _, err := store.Snippets.Insert(ctx, 1, 0, "Count rows", "SELECT COUNT(*) FROM snippets;", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+		second, _, err := store.Snippets.Insert(ctx, 1, 0, "Vacuum", "VACUUM ANALYZE;", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+		third, _, err := store.Snippets.Insert(ctx, 1, 0, "Explain", "EXPLAIN ANALYZE SELECT 1;", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+
+		for _, snippetID := range []int{first, second, third, second} {
+			assert.NilError(t, m.AddSnippet(ctx, id, snippetID))
+		}
+
+		err = m.AddSnippet(ctx, id, 99)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.AddSnippet(ctx, 99, first)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		order := func() []int {
+			t.Helper()
+
+			snippets, err := m.Snippets(ctx, id)
+			assert.NilError(t, err)
+
+			ids := []int{}
+			for _, s := range snippets {
+				ids = append(ids, s.ID)
+			}
+
+			return ids
+		}
+
+		// Adding a snippet twice kept its place
+		assert.Equal(t, len(order()), 3)
+		assert.Equal(t, order()[1], second)
+
+		assert.NilError(t, m.MoveSnippet(ctx, id, third, true))
+		assert.Equal(t, order()[1], third)
+
+		assert.NilError(t, m.MoveSnippet(ctx, id, first, false))
+		assert.Equal(t, order()[0], third)
+		assert.Equal(t, order()[1], first)
+
+		// The ends stay where they are
+		assert.NilError(t, m.MoveSnippet(ctx, id, third, true))
+		assert.NilError(t, m.MoveSnippet(ctx, id, second, false))
+		assert.Equal(t, order()[0], third)
+		assert.Equal(t, order()[2], second)
+
+		err = m.MoveSnippet(ctx, id, 99, true)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		collections, err = m.BySnippet(ctx, first)
+		assert.NilError(t, err)
+		assert.Equal(t, len(collections), 1)
+		assert.Equal(t, collections[0].ID, id)
+
+		assert.NilError(t, m.RemoveSnippet(ctx, id, first))
+
+		err = m.RemoveSnippet(ctx, id, first)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// A deleted snippet leaves its collections
+		assert.NilError(t, store.Snippets.Delete(ctx, third))
+		assert.Equal(t, len(order()), 1)
+		assert.Equal(t, order()[0], second)
+
+		assert.NilError(t, m.Delete(ctx, id))
+
+		_, err = m.Get(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		collections, err = m.BySnippet(ctx, second)
+		assert.NilError(t, err)
+		assert.Equal(t, len(collections), 0)
+
+		err = m.Update(ctx, id, "SQL", "", models.VisibilityPrivate)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
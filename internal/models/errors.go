@@ -1,9 +1,75 @@
 package models
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
 
 var ErrNoRecord = errors.New("models: no matching record found")
 
 var ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 var ErrDuplicateEmail = errors.New("models: duplicate email")
+
+// ErrDuplicateSlug is returned when a new snippet couldn't get a slug of its own.
+var ErrDuplicateSlug = errors.New("models: duplicate slug")
+
+var ErrAccountDisabled = errors.New("models: account disabled")
+
+// ErrLastOwner is returned when a change would leave an organization without an owner.
+var ErrLastOwner = errors.New("models: last owner of the organization")
+
+// ErrCanceled is returned when a query is abandoned because its context was canceled,
+// which usually means that the client went away. It isn't a failure of the database.
+var ErrCanceled = errors.New("models: query canceled")
+
+// WithTimeout returns the context for the queries of one model method, which is
+// canceled after timeout. A zero timeout leaves the deadline of ctx as it is.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ContextError returns err, wrapped in ErrCanceled if ctx was canceled or in
+// context.DeadlineExceeded if it timed out. Each driver reports an interrupted
+// query with an error of its own, so the context is the only reliable sign.
+func ContextError(ctx context.Context, err error) error {
+	switch {
+	case err == nil || ctx.Err() == nil:
+		return err
+	case errors.Is(ctx.Err(), context.Canceled):
+		if errors.Is(err, ErrCanceled) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	default:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+}
+
+// expectOneRow turns the result of an UPDATE or DELETE that matched no rows into ErrNoRecord.
+func expectOneRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestContextError(t *testing.T) {
+	driverErr := errors.New("pq: canceling statement due to user request")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timedOut, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		canceled bool
+		deadline bool
+	}{
+		{
+			name: "No error",
+			ctx:  canceled,
+		},
+		{
+			name: "Live context",
+			ctx:  context.Background(),
+			err:  driverErr,
+		},
+		{
+			name:     "Canceled",
+			ctx:      canceled,
+			err:      driverErr,
+			canceled: true,
+		},
+		{
+			name:     "Timed out",
+			ctx:      timedOut,
+			err:      driverErr,
+			deadline: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.ContextError(tt.ctx, tt.err)
+
+			assert.Equal(t, errors.Is(err, tt.err), true)
+			assert.Equal(t, errors.Is(err, models.ErrCanceled), tt.canceled)
+			assert.Equal(t, errors.Is(err, context.DeadlineExceeded), tt.deadline)
+		})
+	}
+}
+
+func TestCanceledQuery(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		if store.DB == nil {
+			t.Skip("models: the memory backend never blocks, so it ignores the context")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := store.Snippets.Latest(ctx)
+		assert.Equal(t, errors.Is(err, models.ErrCanceled), true)
+
+		err = store.Reports.Moderate(ctx, 1, 1, models.ModerationHide)
+		assert.Equal(t, errors.Is(err, models.ErrCanceled), true)
+	})
+}
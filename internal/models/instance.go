@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type InstanceModelInterface interface {
+	Stats(ctx context.Context) (*Stats, error)
+	PurgeSessions(ctx context.Context) (int, error)
+}
+
+// Stats counts what the instance holds, for operators.
+type Stats struct {
+	Users           int
+	DisabledUsers   int
+	Moderators      int
+	Admins          int
+	Snippets        int
+	ExpiredSnippets int
+	HiddenSnippets  int
+	OpenReports     int
+	Sessions        int
+}
+
+// InstanceModel works across the tables of the other models.
+type InstanceModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *InstanceModel) Stats(ctx context.Context) (*Stats, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM users WHERE disabled),
+		(SELECT COUNT(*) FROM users WHERE role = $1),
+		(SELECT COUNT(*) FROM users WHERE role = $2),
+		(SELECT COUNT(*) FROM snippets),
+		(SELECT COUNT(*) FROM snippets WHERE expires <= now()),
+		(SELECT COUNT(*) FROM snippets WHERE hidden),
+		(SELECT COUNT(DISTINCT snippet_id) FROM snippet_reports WHERE resolved IS NULL),
+		(SELECT COUNT(*) FROM sessions WHERE expiry > now())`
+
+	s := &Stats{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, RoleModerator, RoleAdmin).Scan(&s.Users, &s.DisabledUsers, &s.Moderators, &s.Admins,
+		&s.Snippets, &s.ExpiredSnippets, &s.HiddenSnippets, &s.OpenReports, &s.Sessions)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+// PurgeSessions deletes the expired rows of the session store. The web application
+// cleans them up on its own every few minutes, this is for when it isn't running.
+func (m *InstanceModel) PurgeSessions(ctx context.Context) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM sessions WHERE expiry <= now()`
+
+	result, err := m.DB.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), ContextError(ctx, err)
+}
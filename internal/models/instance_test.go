@@ -0,0 +1,74 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestInstanceModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		_, _, err := store.Snippets.Insert(ctx, 1, 0, "An old silent pond", "An old silent pond...", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+		_, _, err = store.Snippets.Insert(ctx, 1, 0, "A frog jumps", "Into the pond", models.ContentTypeText, 0)
+		assert.NilError(t, err)
+		assert.NilError(t, store.Users.SetRole(ctx, 1, models.RoleAdmin))
+
+		sessions := store.SessionStore(nil)
+		defer sessions.StopCleanup()
+
+		assert.NilError(t, sessions.Commit("live", []byte("data"), time.Now().Add(time.Hour)))
+		assert.NilError(t, sessions.Commit("expired", []byte("data"), time.Now().Add(-time.Hour)))
+
+		stats, err := store.Instance.Stats(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, *stats, models.Stats{
+			Users:           1,
+			Admins:          1,
+			Snippets:        2,
+			ExpiredSnippets: 1,
+			Sessions:        1,
+		})
+
+		n, err := store.Instance.PurgeSessions(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+	})
+}
+
+func TestSessionStore(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		sessions := store.SessionStore(nil)
+		defer sessions.StopCleanup()
+
+		assert.NilError(t, sessions.Commit("token", []byte("first"), time.Now().Add(time.Hour)))
+		// Committing again replaces the data
+		assert.NilError(t, sessions.Commit("token", []byte("second"), time.Now().Add(time.Hour)))
+		assert.NilError(t, sessions.Commit("expired", []byte("data"), time.Now().Add(-time.Minute)))
+
+		b, found, err := sessions.Find("token")
+		assert.NilError(t, err)
+		assert.Equal(t, found, true)
+		assert.Equal(t, string(b), "second")
+
+		_, found, err = sessions.Find("expired")
+		assert.NilError(t, err)
+		assert.Equal(t, found, false)
+
+		all, err := sessions.All()
+		assert.NilError(t, err)
+		assert.Equal(t, len(all), 1)
+
+		assert.NilError(t, sessions.Delete("token"))
+
+		_, found, err = sessions.Find("token")
+		assert.NilError(t, err)
+		assert.Equal(t, found, false)
+	})
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type LoginAttemptModelInterface interface {
+	Failures(ctx context.Context, email, ip string, since time.Time) (*LoginFailures, error)
+	RecordFailure(ctx context.Context, email, ip string) error
+	Reset(ctx context.Context, email string) error
+	RecordLockout(ctx context.Context, email, ip string, failures int, until time.Time) error
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// LoginFailures summarises the failed login attempts made against an account
+// and from an IP address since a point in time. The time of the latest failure is
+// kept for each of them, so that the delays they call for are counted apart.
+type LoginFailures struct {
+	Email     int
+	IP        int
+	EmailLast time.Time
+	IPLast    time.Time
+}
+
+// Failed attempts are kept in PostgreSQL rather than in memory so that every
+// instance behind the load balancer sees the same counts.
+type LoginAttemptModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Timestamps are written from Go in UTC so that the comparisons below don't
+// depend on the timezone of the database server. Emails are lower-cased so that
+// changing the case of an address doesn't reset its count.
+func (m *LoginAttemptModel) Failures(ctx context.Context, email, ip string, since time.Time) (*LoginFailures, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `SELECT COUNT(*) FILTER (WHERE email = $1), COUNT(*) FILTER (WHERE ip = $2),
+	MAX(created) FILTER (WHERE email = $1), MAX(created) FILTER (WHERE ip = $2)
+	FROM login_attempts WHERE created > $3 AND (email = $1 OR ip = $2)`
+
+	f := &LoginFailures{}
+	var emailLast, ipLast sql.NullTime
+
+	err := m.DB.QueryRowContext(ctx, stmt, email, ip, since.UTC()).Scan(&f.Email, &f.IP, &emailLast, &ipLast)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	if emailLast.Valid {
+		f.EmailLast = emailLast.Time
+	}
+	if ipLast.Valid {
+		f.IPLast = ipLast.Time
+	}
+
+	return f, nil
+}
+
+func (m *LoginAttemptModel) RecordFailure(ctx context.Context, email, ip string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `INSERT INTO login_attempts (email, ip, created) VALUES ($1, $2, $3)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email, ip, time.Now().UTC())
+	return ContextError(ctx, err)
+}
+
+// Reset forgets the failed attempts against an account after a successful login.
+// Attempts from the IP address are kept so that spraying many accounts from one
+// address is still throttled.
+func (m *LoginAttemptModel) Reset(ctx context.Context, email string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `DELETE FROM login_attempts WHERE email = $1`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email)
+	return ContextError(ctx, err)
+}
+
+// RecordLockout writes an audit record for a lockout.
+func (m *LoginAttemptModel) RecordLockout(ctx context.Context, email, ip string, failures int, until time.Time) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `INSERT INTO login_lockouts (email, ip, failures, locked_until, created)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email, ip, failures, until.UTC(), time.Now().UTC())
+	return ContextError(ctx, err)
+}
+
+// Purge deletes the attempts made before a point in time and the lockouts that had
+// ended by then, and returns how many rows were removed.
+func (m *LoginAttemptModel) Purge(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	total := 0
+
+	for _, stmt := range []string{
+		`DELETE FROM login_attempts WHERE created < $1`,
+		`DELETE FROM login_lockouts WHERE locked_until < $1`,
+	} {
+		result, err := tx.ExecContext(ctx, stmt, before.UTC())
+		if err != nil {
+			return 0, ContextError(ctx, err)
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, ContextError(ctx, err)
+		}
+
+		total += int(n)
+	}
+
+	return total, ContextError(ctx, tx.Commit())
+}
@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// collectionSnippet is the place of a snippet in a collection.
+type collectionSnippet struct {
+	collectionID int
+	snippetID    int
+	position     int
+}
+
+type CollectionModel struct {
+	DB *DB
+}
+
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastCollectionID++
+	m.DB.collections[m.DB.lastCollectionID] = &models.Collection{
+		ID:          m.DB.lastCollectionID,
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		Visibility:  visibility,
+		Created:     now(),
+	}
+
+	return m.DB.lastCollectionID, nil
+}
+
+func (m *CollectionModel) Get(ctx context.Context, id int) (*models.Collection, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	c, ok := m.DB.collections[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	cc := *c
+	return &cc, nil
+}
+
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.collections[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	c.Title = title
+	c.Description = description
+	c.Visibility = visibility
+
+	return nil
+}
+
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.collections[id]; !ok {
+		return models.ErrNoRecord
+	}
+
+	delete(m.DB.collections, id)
+
+	m.DB.collectionSnippets = slices.DeleteFunc(m.DB.collectionSnippets, func(cs *collectionSnippet) bool {
+		return cs.collectionID == id
+	})
+
+	return nil
+}
+
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*models.Collection, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.sortedCollections(func(c *models.Collection) bool {
+		return c.UserID == userID
+	}), nil
+}
+
+func (m *CollectionModel) BySnippet(ctx context.Context, snippetID int) ([]*models.Collection, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	in := map[int]bool{}
+	for _, cs := range m.DB.collectionSnippets {
+		if cs.snippetID == snippetID {
+			in[cs.collectionID] = true
+		}
+	}
+
+	return m.DB.sortedCollections(func(c *models.Collection) bool {
+		return in[c.ID]
+	}), nil
+}
+
+func (m *CollectionModel) Snippets(ctx context.Context, id int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+
+	for _, cs := range m.DB.orderedCollectionSnippets(id) {
+		c := *m.DB.snippets[cs.snippetID]
+		snippets = append(snippets, &c)
+	}
+
+	return snippets, nil
+}
+
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Both ids must exist, like the foreign keys require in the database
+	_, collectionOK := m.DB.collections[id]
+	_, snippetOK := m.DB.snippets[snippetID]
+	if !collectionOK || !snippetOK {
+		return models.ErrNoRecord
+	}
+
+	position := 0
+
+	for _, cs := range m.DB.collectionSnippets {
+		if cs.collectionID != id {
+			continue
+		}
+		if cs.snippetID == snippetID {
+			return nil
+		}
+
+		position = max(position, cs.position)
+	}
+
+	m.DB.collectionSnippets = append(m.DB.collectionSnippets, &collectionSnippet{
+		collectionID: id,
+		snippetID:    snippetID,
+		position:     position + 1,
+	})
+
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	n := len(m.DB.collectionSnippets)
+
+	m.DB.collectionSnippets = slices.DeleteFunc(m.DB.collectionSnippets, func(cs *collectionSnippet) bool {
+		return cs.collectionID == id && cs.snippetID == snippetID
+	})
+
+	if len(m.DB.collectionSnippets) == n {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *CollectionModel) MoveSnippet(ctx context.Context, id, snippetID int, up bool) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if !slices.ContainsFunc(m.DB.collectionSnippets, func(cs *collectionSnippet) bool {
+		return cs.collectionID == id && cs.snippetID == snippetID
+	}) {
+		return models.ErrNoRecord
+	}
+
+	// Expired snippets aren't shown, so they are skipped like in the database
+	ordered := m.DB.orderedCollectionSnippets(id)
+
+	i := slices.IndexFunc(ordered, func(cs *collectionSnippet) bool {
+		return cs.snippetID == snippetID
+	})
+
+	j := i + 1
+	if up {
+		j = i - 1
+	}
+
+	// An expired snippet being moved is not in ordered, nor is there a neighbour past either end
+	if i < 0 || j < 0 || j >= len(ordered) {
+		return nil
+	}
+
+	ordered[i].position, ordered[j].position = ordered[j].position, ordered[i].position
+
+	return nil
+}
+
+// sortedCollections returns copies of the collections that match keep, by title.
+// The caller must hold the lock.
+func (db *DB) sortedCollections(keep func(c *models.Collection) bool) []*models.Collection {
+	collections := []*models.Collection{}
+
+	for _, c := range db.collections {
+		if keep(c) {
+			cc := *c
+			collections = append(collections, &cc)
+		}
+	}
+
+	slices.SortFunc(collections, func(a, b *models.Collection) int {
+		return cmp.Or(strings.Compare(a.Title, b.Title), cmp.Compare(a.ID, b.ID))
+	})
+
+	return collections
+}
+
+// orderedCollectionSnippets returns the places of the unexpired snippets of a
+// collection, in order. The caller must hold the lock.
+func (db *DB) orderedCollectionSnippets(id int) []*collectionSnippet {
+	t := now()
+	ordered := []*collectionSnippet{}
+
+	for _, cs := range db.collectionSnippets {
+		if cs.collectionID == id && db.snippets[cs.snippetID].Expires.After(t) {
+			ordered = append(ordered, cs)
+		}
+	}
+
+	slices.SortFunc(ordered, func(a, b *collectionSnippet) int {
+		return cmp.Compare(a.position, b.position)
+	})
+
+	return ordered
+}
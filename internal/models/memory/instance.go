@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type InstanceModel struct {
+	DB *DB
+}
+
+func (m *InstanceModel) Stats(ctx context.Context) (*models.Stats, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	s := &models.Stats{Users: len(m.DB.users), Snippets: len(m.DB.snippets)}
+
+	for _, u := range m.DB.users {
+		if u.Disabled {
+			s.DisabledUsers++
+		}
+
+		switch u.Role {
+		case models.RoleModerator:
+			s.Moderators++
+		case models.RoleAdmin:
+			s.Admins++
+		}
+	}
+
+	for _, snippet := range m.DB.snippets {
+		if !snippet.Expires.After(t) {
+			s.ExpiredSnippets++
+		}
+		if snippet.Hidden {
+			s.HiddenSnippets++
+		}
+	}
+
+	reported := map[int]bool{}
+	for _, r := range m.DB.reports {
+		if !r.resolved {
+			reported[r.snippetID] = true
+		}
+	}
+	s.OpenReports = len(reported)
+
+	for _, session := range m.DB.sessions {
+		if session.expiry.After(t) {
+			s.Sessions++
+		}
+	}
+
+	return s, nil
+}
+
+func (m *InstanceModel) PurgeSessions(ctx context.Context) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	return m.DB.deleteExpiredSessions(), nil
+}
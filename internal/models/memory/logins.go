@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type loginAttempt struct {
+	email   string
+	ip      string
+	created time.Time
+}
+
+type loginLockout struct {
+	email       string
+	ip          string
+	failures    int
+	lockedUntil time.Time
+	created     time.Time
+}
+
+type LoginAttemptModel struct {
+	DB *DB
+}
+
+// Emails are lower-cased so that changing the case of an address doesn't reset its count.
+func (m *LoginAttemptModel) Failures(ctx context.Context, email, ip string, since time.Time) (*models.LoginFailures, error) {
+	email = strings.ToLower(email)
+
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	f := &models.LoginFailures{}
+
+	for _, a := range m.DB.loginAttempts {
+		if !a.created.After(since) || (a.email != email && a.ip != ip) {
+			continue
+		}
+
+		if a.email == email {
+			f.Email++
+			if a.created.After(f.EmailLast) {
+				f.EmailLast = a.created
+			}
+		}
+		if a.ip == ip {
+			f.IP++
+			if a.created.After(f.IPLast) {
+				f.IPLast = a.created
+			}
+		}
+	}
+
+	return f, nil
+}
+
+func (m *LoginAttemptModel) RecordFailure(ctx context.Context, email, ip string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.loginAttempts = append(m.DB.loginAttempts, loginAttempt{
+		email:   strings.ToLower(email),
+		ip:      ip,
+		created: now(),
+	})
+
+	return nil
+}
+
+func (m *LoginAttemptModel) Reset(ctx context.Context, email string) error {
+	email = strings.ToLower(email)
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.loginAttempts = slices.DeleteFunc(m.DB.loginAttempts, func(a loginAttempt) bool {
+		return a.email == email
+	})
+
+	return nil
+}
+
+func (m *LoginAttemptModel) RecordLockout(ctx context.Context, email, ip string, failures int, until time.Time) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.loginLockouts = append(m.DB.loginLockouts, loginLockout{
+		email:       strings.ToLower(email),
+		ip:          ip,
+		failures:    failures,
+		lockedUntil: until,
+		created:     now(),
+	})
+
+	return nil
+}
+
+func (m *LoginAttemptModel) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	n := len(m.DB.loginAttempts) + len(m.DB.loginLockouts)
+
+	m.DB.loginAttempts = slices.DeleteFunc(m.DB.loginAttempts, func(a loginAttempt) bool {
+		return a.created.Before(before)
+	})
+	m.DB.loginLockouts = slices.DeleteFunc(m.DB.loginLockouts, func(l loginLockout) bool {
+		return l.lockedUntil.Before(before)
+	})
+
+	return n - len(m.DB.loginAttempts) - len(m.DB.loginLockouts), nil
+}
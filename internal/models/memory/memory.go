@@ -0,0 +1,89 @@
+// Package memory implements the model interfaces of internal/models, and a session
+// store for github.com/alexedwards/scs, in memory. Nothing survives a restart, which
+// makes it a fit for demos and for tests that need realistic models without a database.
+//
+// Every model of a DB shares its lock, so an operation that spans models, like
+// moderating a snippet, is as atomic as a transaction would be. The models hand out
+// copies, never the records they hold. Nothing they do blocks for long, so they
+// don't look at the context they are given.
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// DB holds the records of every model. The zero value is not usable, create one with New.
+type DB struct {
+	mu sync.RWMutex
+
+	snippets      map[int]*models.Snippet
+	lastSnippetID int
+
+	users      map[int]*models.User
+	lastUserID int
+
+	loginAttempts []loginAttempt
+	loginLockouts []loginLockout
+
+	reports      []*report
+	actions      []*moderationAction
+	lastActionID int
+
+	collections        map[int]*models.Collection
+	lastCollectionID   int
+	collectionSnippets []*collectionSnippet
+
+	orgs             map[int]*models.Organization
+	lastOrgID        int
+	orgMembers       []*orgMember
+	invitations      []*invitation
+	lastInvitationID int
+
+	webhooks       map[int]*models.Webhook
+	lastWebhookID  int
+	deliveries     map[int]*models.Delivery
+	lastDeliveryID int
+
+	sessions map[string]session
+}
+
+func New() *DB {
+	return &DB{
+		snippets:    map[int]*models.Snippet{},
+		users:       map[int]*models.User{},
+		collections: map[int]*models.Collection{},
+		orgs:        map[int]*models.Organization{},
+		webhooks:    map[int]*models.Webhook{},
+		deliveries:  map[int]*models.Delivery{},
+		sessions:    map[string]session{},
+	}
+}
+
+// PingContext always succeeds, it lets a DB stand in for a connection pool in the health checks.
+func (db *DB) PingContext(ctx context.Context) error {
+	return nil
+}
+
+// contains reports whether s contains substr, ignoring case like ILIKE does.
+func contains(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// page returns the items of a LIMIT and OFFSET.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+
+	return items[offset:min(offset+limit, len(items))]
+}
+
+// now is the current time, rounded like the timestamps PostgreSQL stores.
+func now() time.Time {
+	return time.Now().Round(time.Microsecond)
+}
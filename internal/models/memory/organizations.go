@@ -0,0 +1,322 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// orgMember is the role of a user in an organization.
+type orgMember struct {
+	orgID  int
+	userID int
+	role   string
+	joined time.Time
+}
+
+// invitation is a pending invitation with the hash of its token.
+type invitation struct {
+	models.Invitation
+	tokenHash []byte
+}
+
+type OrganizationModel struct {
+	DB *DB
+}
+
+func (m *OrganizationModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// The owner must exist, like the foreign key requires in the database
+	if _, ok := m.DB.users[ownerID]; !ok {
+		return 0, models.ErrNoRecord
+	}
+
+	created := now()
+
+	m.DB.lastOrgID++
+	m.DB.orgs[m.DB.lastOrgID] = &models.Organization{
+		ID:      m.DB.lastOrgID,
+		Name:    name,
+		Created: created,
+	}
+
+	m.DB.orgMembers = append(m.DB.orgMembers, &orgMember{
+		orgID:  m.DB.lastOrgID,
+		userID: ownerID,
+		role:   models.OrgRoleOwner,
+		joined: created,
+	})
+
+	return m.DB.lastOrgID, nil
+}
+
+func (m *OrganizationModel) Get(ctx context.Context, id int) (*models.Organization, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	o, ok := m.DB.orgs[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	c := *o
+	return &c, nil
+}
+
+func (m *OrganizationModel) ByUser(ctx context.Context, userID int) ([]*models.Membership, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	members := m.DB.memberships(func(om *orgMember) bool {
+		return om.userID == userID
+	})
+
+	slices.SortFunc(members, func(a, b *models.Membership) int {
+		return cmp.Or(strings.Compare(a.OrgName, b.OrgName), cmp.Compare(a.OrgID, b.OrgID))
+	})
+
+	return members, nil
+}
+
+func (m *OrganizationModel) Members(ctx context.Context, id int) ([]*models.Membership, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	members := m.DB.memberships(func(om *orgMember) bool {
+		return om.orgID == id
+	})
+
+	slices.SortFunc(members, func(a, b *models.Membership) int {
+		return cmp.Or(strings.Compare(a.UserName, b.UserName), cmp.Compare(a.UserID, b.UserID))
+	})
+
+	return members, nil
+}
+
+func (m *OrganizationModel) Member(ctx context.Context, id, userID int) (*models.Membership, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	members := m.DB.memberships(func(om *orgMember) bool {
+		return om.orgID == id && om.userID == userID
+	})
+
+	if len(members) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return members[0], nil
+}
+
+func (m *OrganizationModel) SetRole(ctx context.Context, id, userID int, role string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	om := m.DB.orgMember(id, userID)
+	if om == nil {
+		return models.ErrNoRecord
+	}
+
+	if role != models.OrgRoleOwner && m.DB.lastOwner(id, userID) {
+		return models.ErrLastOwner
+	}
+
+	om.role = role
+
+	return nil
+}
+
+func (m *OrganizationModel) RemoveMember(ctx context.Context, id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if m.DB.lastOwner(id, userID) {
+		return models.ErrLastOwner
+	}
+
+	n := len(m.DB.orgMembers)
+
+	m.DB.orgMembers = slices.DeleteFunc(m.DB.orgMembers, func(om *orgMember) bool {
+		return om.orgID == id && om.userID == userID
+	})
+
+	if len(m.DB.orgMembers) == n {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *OrganizationModel) Invite(ctx context.Context, id int, email, role string, invitedBy int) (string, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	o, ok := m.DB.orgs[id]
+	if !ok {
+		return "", models.ErrNoRecord
+	}
+
+	token, hash, err := models.NewInvitationToken()
+	if err != nil {
+		return "", err
+	}
+
+	created := now()
+
+	// Inviting an address again replaces its invitation, like the unique constraint does
+	m.DB.invitations = slices.DeleteFunc(m.DB.invitations, func(i *invitation) bool {
+		return i.OrgID == id && i.Email == email
+	})
+
+	m.DB.lastInvitationID++
+	m.DB.invitations = append(m.DB.invitations, &invitation{
+		Invitation: models.Invitation{
+			ID:        m.DB.lastInvitationID,
+			OrgID:     id,
+			OrgName:   o.Name,
+			Email:     email,
+			Role:      role,
+			InvitedBy: invitedBy,
+			Created:   created,
+			Expires:   created.AddDate(0, 0, models.InvitationDays),
+		},
+		tokenHash: hash,
+	})
+
+	return token, nil
+}
+
+func (m *OrganizationModel) Invitation(ctx context.Context, token string) (*models.Invitation, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	i := m.DB.invitationByToken(token)
+	if i == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	c := i.Invitation
+	return &c, nil
+}
+
+func (m *OrganizationModel) Invitations(ctx context.Context, id int) ([]*models.Invitation, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	invitations := []*models.Invitation{}
+
+	for _, i := range m.DB.invitations {
+		if i.OrgID == id && i.Expires.After(t) {
+			c := i.Invitation
+			invitations = append(invitations, &c)
+		}
+	}
+
+	slices.SortFunc(invitations, func(a, b *models.Invitation) int {
+		return strings.Compare(a.Email, b.Email)
+	})
+
+	return invitations, nil
+}
+
+func (m *OrganizationModel) AcceptInvitation(ctx context.Context, token string, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	i := m.DB.invitationByToken(token)
+	if _, ok := m.DB.users[userID]; i == nil || !ok {
+		return models.ErrNoRecord
+	}
+
+	if m.DB.orgMember(i.OrgID, userID) == nil {
+		m.DB.orgMembers = append(m.DB.orgMembers, &orgMember{
+			orgID:  i.OrgID,
+			userID: userID,
+			role:   i.Role,
+			joined: now(),
+		})
+	}
+
+	m.DB.invitations = slices.DeleteFunc(m.DB.invitations, func(other *invitation) bool {
+		return other == i
+	})
+
+	return nil
+}
+
+// memberships returns the memberships that match keep, with the names of their
+// organization and user. The caller must hold the lock.
+func (db *DB) memberships(keep func(om *orgMember) bool) []*models.Membership {
+	members := []*models.Membership{}
+
+	for _, om := range db.orgMembers {
+		if !keep(om) {
+			continue
+		}
+
+		user := db.users[om.userID]
+
+		members = append(members, &models.Membership{
+			OrgID:    om.orgID,
+			OrgName:  db.orgs[om.orgID].Name,
+			UserID:   om.userID,
+			UserName: user.Name,
+			Email:    user.Email,
+			Role:     om.role,
+			Joined:   om.joined,
+		})
+	}
+
+	return members
+}
+
+// orgMember returns the membership of a user in an organization, or nil if there is
+// none. The caller must hold the lock.
+func (db *DB) orgMember(orgID, userID int) *orgMember {
+	for _, om := range db.orgMembers {
+		if om.orgID == orgID && om.userID == userID {
+			return om
+		}
+	}
+
+	return nil
+}
+
+// lastOwner reports whether userID is the only owner of an organization. The caller
+// must hold the lock.
+func (db *DB) lastOwner(orgID, userID int) bool {
+	owners := 0
+	last := false
+
+	for _, om := range db.orgMembers {
+		if om.orgID == orgID && om.role == models.OrgRoleOwner {
+			owners++
+			last = om.userID == userID
+		}
+	}
+
+	return owners == 1 && last
+}
+
+// invitationByToken returns the unexpired invitation with the given token, or nil if
+// there is none. The caller must hold the lock.
+func (db *DB) invitationByToken(token string) *invitation {
+	hash := models.HashInvitationToken(token)
+	t := now()
+
+	for _, i := range db.invitations {
+		if bytes.Equal(i.tokenHash, hash) && i.Expires.After(t) {
+			return i
+		}
+	}
+
+	return nil
+}
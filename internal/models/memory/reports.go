@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type report struct {
+	snippetID  int
+	reporterID int
+	reason     string
+	created    time.Time
+	resolved   bool
+}
+
+type moderationAction struct {
+	id          int
+	snippetID   int
+	moderatorID int
+	action      string
+	created     time.Time
+}
+
+type ReportModel struct {
+	DB *DB
+}
+
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reason string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Both ids must exist, like the foreign keys require in the database
+	_, snippetOK := m.DB.snippets[snippetID]
+	_, userOK := m.DB.users[reporterID]
+	if !snippetOK || !userOK {
+		return models.ErrNoRecord
+	}
+
+	m.DB.reports = append(m.DB.reports, &report{
+		snippetID:  snippetID,
+		reporterID: reporterID,
+		reason:     reason,
+		created:    now(),
+	})
+
+	return nil
+}
+
+func (m *ReportModel) Queue(ctx context.Context) ([]*models.ReportedSnippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	bySnippet := map[int]*models.ReportedSnippet{}
+	queue := []*models.ReportedSnippet{}
+
+	// Reports are appended as they come in, so the reasons are already in order
+	for _, r := range m.DB.reports {
+		if r.resolved {
+			continue
+		}
+
+		rs, ok := bySnippet[r.snippetID]
+		if !ok {
+			s := m.DB.snippets[r.snippetID]
+			rs = &models.ReportedSnippet{SnippetID: s.ID, Slug: s.Slug, Title: s.Title, Hidden: s.Hidden}
+			bySnippet[r.snippetID] = rs
+			queue = append(queue, rs)
+		}
+
+		rs.Reasons = append(rs.Reasons, r.reason)
+		rs.LastReported = r.created
+	}
+
+	slices.SortStableFunc(queue, func(a, b *models.ReportedSnippet) int {
+		return b.LastReported.Compare(a.LastReported)
+	})
+
+	return queue, nil
+}
+
+func (m *ReportModel) Moderate(ctx context.Context, snippetID, moderatorID int, action string) error {
+	if action != models.ModerationHide && action != models.ModerationRestore && action != models.ModerationDelete {
+		return fmt.Errorf("models: unknown moderation action %q", action)
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[snippetID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	switch action {
+	case models.ModerationHide:
+		s.Hidden = true
+	case models.ModerationRestore:
+		s.Hidden = false
+	case models.ModerationDelete:
+		m.DB.deleteSnippet(snippetID)
+	}
+
+	for _, r := range m.DB.reports {
+		if r.snippetID == snippetID {
+			r.resolved = true
+		}
+	}
+
+	m.DB.lastActionID++
+	m.DB.actions = append(m.DB.actions, &moderationAction{
+		id:          m.DB.lastActionID,
+		snippetID:   snippetID,
+		moderatorID: moderatorID,
+		action:      action,
+		created:     now(),
+	})
+
+	return nil
+}
+
+func (m *ReportModel) Log(ctx context.Context, limit int) ([]*models.ModerationAction, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	actions := []*models.ModerationAction{}
+
+	for _, a := range m.DB.actions {
+		ma := &models.ModerationAction{ID: a.id, SnippetID: a.snippetID, Action: a.action, Created: a.created}
+		if u, ok := m.DB.users[a.moderatorID]; ok {
+			ma.ModeratorName = u.Name
+		}
+
+		actions = append(actions, ma)
+	}
+
+	slices.SortFunc(actions, func(a, b *models.ModerationAction) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return page(actions, limit, 0), nil
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"time"
+)
+
+type session struct {
+	data   []byte
+	expiry time.Time
+}
+
+// SessionStore is a session store for github.com/alexedwards/scs that keeps the sessions
+// in a DB, so that InstanceModel can count and purge them. Expired sessions are never
+// returned, and are removed by PurgeSessions or when the store is cleaned up in the background.
+type SessionStore struct {
+	DB          *DB
+	stopCleanup chan bool
+}
+
+// NewSessionStore returns a session store that deletes the expired sessions every five minutes.
+func NewSessionStore(db *DB) *SessionStore {
+	return NewSessionStoreWithCleanupInterval(db, 5*time.Minute)
+}
+
+// NewSessionStoreWithCleanupInterval returns a session store that deletes the expired sessions
+// at the given interval, or never if it is zero.
+func NewSessionStoreWithCleanupInterval(db *DB, cleanupInterval time.Duration) *SessionStore {
+	s := &SessionStore{DB: db}
+
+	if cleanupInterval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	session, ok := s.DB.sessions[token]
+	if !ok || !session.expiry.After(time.Now()) {
+		return nil, false, nil
+	}
+
+	return session.data, true, nil
+}
+
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	s.DB.sessions[token] = session{data: b, expiry: expiry}
+	return nil
+}
+
+func (s *SessionStore) Delete(token string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	delete(s.DB.sessions, token)
+	return nil
+}
+
+func (s *SessionStore) All() (map[string][]byte, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	t := time.Now()
+	sessions := map[string][]byte{}
+
+	for token, session := range s.DB.sessions {
+		if session.expiry.After(t) {
+			sessions[token] = session.data
+		}
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.DB.mu.Lock()
+			s.DB.deleteExpiredSessions()
+			s.DB.mu.Unlock()
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup stops the background cleanup goroutine, if there is one.
+func (s *SessionStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+// deleteExpiredSessions returns how many sessions it deleted. The caller must hold the write lock.
+func (db *DB) deleteExpiredSessions() int {
+	t := time.Now()
+	n := 0
+
+	for token, session := range db.sessions {
+		if !session.expiry.After(t) {
+			delete(db.sessions, token)
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type SnippetModel struct {
+	DB *DB
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	created := now()
+
+	return models.InsertWithSlug(func(slug string) (int, error) {
+		if m.DB.snippetBySlug(slug) != nil {
+			return 0, models.ErrDuplicateSlug
+		}
+
+		m.DB.lastSnippetID++
+		m.DB.snippets[m.DB.lastSnippetID] = &models.Snippet{
+			ID:          m.DB.lastSnippetID,
+			Slug:        slug,
+			Title:       title,
+			Content:     content,
+			ContentType: contentType,
+			Created:     created,
+			Updated:     created,
+			Expires:     created.AddDate(0, 0, expires),
+			UserID:      userID,
+			OrgID:       orgID,
+		}
+
+		return m.DB.lastSnippetID, nil
+	})
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, contentType string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.Expires.After(now()) {
+		return models.ErrNoRecord
+	}
+
+	s.Title = title
+	s.Content = content
+	s.ContentType = contentType
+	s.Updated = now()
+
+	return nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.Expires.After(now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	c := *s
+	return &c, nil
+}
+
+// GetLegacy finds nothing, the memory store never had snippets without slugs.
+func (m *SnippetModel) GetLegacy(ctx context.Context, id int) (*models.Snippet, error) {
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	s := m.DB.snippetBySlug(slug)
+	if s == nil || !s.Expires.After(now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	c := *s
+	return &c, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+
+	snippets := m.DB.sortedSnippets(func(s *models.Snippet) bool {
+		return s.Expires.After(t) && !s.Hidden
+	})
+
+	return page(snippets, 10, 0), nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, limit int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+
+	snippets := m.DB.sortedSnippets(func(s *models.Snippet) bool {
+		return s.UserID == userID && s.Expires.After(t) && !s.Hidden
+	})
+
+	return page(snippets, limit, 0), nil
+}
+
+func (m *SnippetModel) ByOrg(ctx context.Context, orgID int, limit int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+
+	snippets := m.DB.sortedSnippets(func(s *models.Snippet) bool {
+		return s.OrgID == orgID && s.Expires.After(t) && !s.Hidden
+	})
+
+	return page(snippets, limit, 0), nil
+}
+
+func (m *SnippetModel) List(ctx context.Context, search string, limit, offset int) ([]*models.Snippet, int, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := m.DB.sortedSnippets(func(s *models.Snippet) bool {
+		return search == "" || contains(s.Title, search) || contains(s.Content, search)
+	})
+
+	return page(snippets, limit, offset), len(snippets), nil
+}
+
+func (m *SnippetModel) After(ctx context.Context, afterID, limit int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := m.DB.sortedSnippets(func(s *models.Snippet) bool {
+		return s.ID > afterID
+	})
+	slices.Reverse(snippets)
+
+	return page(snippets, limit, 0), nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	return m.DB.deleteSnippet(id)
+}
+
+func (m *SnippetModel) DeleteExpired(ctx context.Context) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t := now()
+	n := 0
+
+	for id, s := range m.DB.snippets {
+		if !s.Expires.After(t) {
+			m.DB.deleteSnippet(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// sortedSnippets returns copies of the snippets that match keep, newest first.
+// The caller must hold the lock.
+func (db *DB) sortedSnippets(keep func(s *models.Snippet) bool) []*models.Snippet {
+	snippets := []*models.Snippet{}
+
+	for _, s := range db.snippets {
+		if keep(s) {
+			c := *s
+			snippets = append(snippets, &c)
+		}
+	}
+
+	slices.SortFunc(snippets, func(a, b *models.Snippet) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return snippets
+}
+
+// snippetBySlug returns the snippet with the given slug, expired or not, or nil. The
+// slugs aren't indexed, a scan is fast enough for the snippets of a demo. The caller
+// must hold the lock.
+func (db *DB) snippetBySlug(slug string) *models.Snippet {
+	for _, s := range db.snippets {
+		if s.Slug == slug {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// deleteSnippet removes a snippet, its reports and its places in collections, like the
+// foreign keys do in the database. The caller must hold the write lock.
+func (db *DB) deleteSnippet(id int) error {
+	if _, ok := db.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+
+	delete(db.snippets, id)
+
+	db.reports = slices.DeleteFunc(db.reports, func(r *report) bool {
+		return r.snippetID == id
+	})
+
+	db.collectionSnippets = slices.DeleteFunc(db.collectionSnippets, func(cs *collectionSnippet) bool {
+		return cs.snippetID == id
+	})
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserModel struct {
+	DB *DB
+}
+
+// copyUser returns a copy of u without the password hash, which the database
+// backends never read back either.
+func copyUser(u *models.User) *models.User {
+	c := *u
+	c.HashedPassword = nil
+	return &c
+}
+
+// userByEmail matches the email exactly, like the unique constraint on users.
+// The caller must hold the lock.
+func (db *DB) userByEmail(email string) *models.User {
+	for _, u := range db.users {
+		if u.Email == email {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return copyUser(u), nil
+}
+
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u := m.DB.userByEmail(email)
+	if u == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	return copyUser(u), nil
+}
+
+// Authenticate compares the password outside of the lock, bcrypt is slow on purpose.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.DB.mu.RLock()
+	u := m.DB.userByEmail(email)
+	if u != nil {
+		u = &models.User{ID: u.ID, HashedPassword: u.HashedPassword, Disabled: u.Disabled}
+	}
+	m.DB.mu.RUnlock()
+
+	if u == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	// Only tell the user that the account is disabled once they have proven they own it
+	if u.Disabled {
+		return 0, models.ErrAccountDisabled
+	}
+
+	return u.ID, nil
+}
+
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if m.DB.userByEmail(email) != nil {
+		return models.ErrDuplicateEmail
+	}
+
+	m.DB.lastUserID++
+	m.DB.users[m.DB.lastUserID] = &models.User{
+		ID:             m.DB.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        now(),
+		Role:           models.RoleUser,
+	}
+
+	return nil
+}
+
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	_, ok := m.DB.users[id]
+	return ok, nil
+}
+
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	m.DB.mu.RLock()
+	u, ok := m.DB.users[id]
+	var currentHashedPassword []byte
+	if ok {
+		currentHashedPassword = u.HashedPassword
+	}
+	m.DB.mu.RUnlock()
+
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	err := bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	return m.update(id, func(u *models.User) {
+		u.HashedPassword = newHashedPassword
+		u.PasswordResetRequired = false
+	})
+}
+
+func (m *UserModel) List(ctx context.Context, search string, limit, offset int) ([]*models.User, int, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	users := []*models.User{}
+
+	for _, u := range m.DB.users {
+		if search == "" || contains(u.Name, search) || contains(u.Email, search) {
+			users = append(users, copyUser(u))
+		}
+	}
+
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return page(users, limit, offset), len(users), nil
+}
+
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return m.update(id, func(u *models.User) {
+		u.Disabled = disabled
+	})
+}
+
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) error {
+	return m.update(id, func(u *models.User) {
+		u.PasswordResetRequired = true
+	})
+}
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	return m.update(id, func(u *models.User) {
+		u.Role = role
+	})
+}
+
+func (m *UserModel) SetPassword(ctx context.Context, id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	return m.update(id, func(u *models.User) {
+		u.HashedPassword = hashedPassword
+		u.PasswordResetRequired = true
+	})
+}
+
+// update applies fn to a user under the write lock.
+func (m *UserModel) update(id int, fn func(u *models.User)) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	fn(u)
+	return nil
+}
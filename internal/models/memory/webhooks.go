@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type WebhookModel struct {
+	DB *DB
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, userID, orgID int, url string, events []string) (int, error) {
+	secret, err := models.NewWebhookSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastWebhookID++
+	m.DB.webhooks[m.DB.lastWebhookID] = &models.Webhook{
+		ID:      m.DB.lastWebhookID,
+		UserID:  userID,
+		OrgID:   orgID,
+		URL:     url,
+		Events:  slices.Clone(events),
+		Secret:  secret,
+		Created: now(),
+	}
+
+	return m.DB.lastWebhookID, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	w, ok := m.DB.webhooks[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return copyWebhook(w), nil
+}
+
+func (m *WebhookModel) ByUser(ctx context.Context, userID int) ([]*models.Webhook, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.sortedWebhooks(func(w *models.Webhook) bool {
+		return w.OrgID == 0 && w.UserID == userID
+	}), nil
+}
+
+func (m *WebhookModel) ByOrg(ctx context.Context, orgID int) ([]*models.Webhook, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.sortedWebhooks(func(w *models.Webhook) bool {
+		return w.OrgID != 0 && w.OrgID == orgID
+	}), nil
+}
+
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.webhooks[id]; !ok {
+		return models.ErrNoRecord
+	}
+
+	delete(m.DB.webhooks, id)
+
+	for deliveryID, d := range m.DB.deliveries {
+		if d.WebhookID == id {
+			delete(m.DB.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+func (m *WebhookModel) Subscribers(ctx context.Context, userID, orgID int, event string) ([]*models.Webhook, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.sortedWebhooks(func(w *models.Webhook) bool {
+		if orgID != 0 {
+			return w.OrgID == orgID && w.Wants(event)
+		}
+
+		return w.OrgID == 0 && w.UserID == userID && w.Wants(event)
+	}), nil
+}
+
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event, payload string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.webhooks[webhookID]; !ok {
+		return 0, models.ErrNoRecord
+	}
+
+	created := now()
+
+	m.DB.lastDeliveryID++
+	m.DB.deliveries[m.DB.lastDeliveryID] = &models.Delivery{
+		ID:        m.DB.lastDeliveryID,
+		WebhookID: webhookID,
+		Event:     event,
+		Payload:   payload,
+		Created:   created,
+		Updated:   created,
+	}
+
+	return m.DB.lastDeliveryID, nil
+}
+
+func (m *WebhookModel) RecordAttempt(ctx context.Context, deliveryID, statusCode int, errMsg string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	d, ok := m.DB.deliveries[deliveryID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	d.StatusCode = statusCode
+	d.Error = errMsg
+	d.Attempts++
+	d.Updated = now()
+
+	return nil
+}
+
+func (m *WebhookModel) Delivery(ctx context.Context, id int) (*models.Delivery, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	d, ok := m.DB.deliveries[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	c := *d
+	return &c, nil
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*models.Delivery, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	deliveries := []*models.Delivery{}
+
+	for _, d := range m.DB.deliveries {
+		if d.WebhookID == webhookID {
+			c := *d
+			deliveries = append(deliveries, &c)
+		}
+	}
+
+	// Newest first, like ORDER BY id DESC
+	slices.SortFunc(deliveries, func(a, b *models.Delivery) int {
+		return b.ID - a.ID
+	})
+
+	return page(deliveries, limit, 0), nil
+}
+
+// sortedWebhooks returns copies of the webhooks that match keep, oldest first. The
+// caller must hold the lock.
+func (db *DB) sortedWebhooks(keep func(w *models.Webhook) bool) []*models.Webhook {
+	webhooks := []*models.Webhook{}
+
+	for _, w := range db.webhooks {
+		if keep(w) {
+			webhooks = append(webhooks, copyWebhook(w))
+		}
+	}
+
+	slices.SortFunc(webhooks, func(a, b *models.Webhook) int {
+		return a.ID - b.ID
+	})
+
+	return webhooks
+}
+
+// copyWebhook copies w along with its events, which would otherwise be shared.
+func copyWebhook(w *models.Webhook) *models.Webhook {
+	c := *w
+	c.Events = slices.Clone(w.Events)
+	return &c
+}
@@ -0,0 +1,103 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// A public collection of Alice's that holds both of her snippets
+var mockCollection = &models.Collection{
+	ID:          1,
+	UserID:      1,
+	Title:       "Haiku",
+	Description: "Poems about ponds",
+	Visibility:  models.VisibilityPublic,
+	Created:     time.Now(),
+}
+
+// A private collection of Alice's that holds her visible snippet
+var mockPrivateCollection = &models.Collection{
+	ID:         2,
+	UserID:     1,
+	Title:      "Drafts",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	return 3, nil
+}
+
+func (m *CollectionModel) Get(ctx context.Context, id int) (*models.Collection, error) {
+	switch id {
+	case 1:
+		return mockCollection, nil
+	case 2:
+		return mockPrivateCollection, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	return collectionExists(id)
+}
+
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	return collectionExists(id)
+}
+
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*models.Collection, error) {
+	if userID == 1 {
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	}
+
+	return []*models.Collection{}, nil
+}
+
+func (m *CollectionModel) BySnippet(ctx context.Context, snippetID int) ([]*models.Collection, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	case 3:
+		return []*models.Collection{mockCollection}, nil
+	default:
+		return []*models.Collection{}, nil
+	}
+}
+
+func (m *CollectionModel) Snippets(ctx context.Context, id int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockSnippet, mockHiddenSnippet}, nil
+	case 2:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	return collectionExists(id)
+}
+
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	return collectionExists(id)
+}
+
+func (m *CollectionModel) MoveSnippet(ctx context.Context, id, snippetID int, up bool) error {
+	return collectionExists(id)
+}
+
+// collectionExists returns ErrNoRecord for the ids of the collections that the mock doesn't have.
+func collectionExists(id int) error {
+	if id != 1 && id != 2 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type InstanceModel struct{}
+
+func (m *InstanceModel) Stats(ctx context.Context) (*models.Stats, error) {
+	return &models.Stats{
+		Users:          3,
+		Moderators:     1,
+		Admins:         1,
+		Snippets:       2,
+		HiddenSnippets: 1,
+		OpenReports:    1,
+		Sessions:       4,
+	}, nil
+}
+
+func (m *InstanceModel) PurgeSessions(ctx context.Context) (int, error) {
+	return 7, nil
+}
@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type LoginAttemptModel struct{}
+
+func (m *LoginAttemptModel) Failures(ctx context.Context, email, ip string, since time.Time) (*models.LoginFailures, error) {
+	switch email {
+	case "locked@example.com":
+		return &models.LoginFailures{Email: 10, IP: 10, EmailLast: time.Now(), IPLast: time.Now()}, nil
+	default:
+		return &models.LoginFailures{}, nil
+	}
+}
+
+func (m *LoginAttemptModel) RecordFailure(ctx context.Context, email, ip string) error {
+	return nil
+}
+
+func (m *LoginAttemptModel) Reset(ctx context.Context, email string) error {
+	return nil
+}
+
+func (m *LoginAttemptModel) RecordLockout(ctx context.Context, email, ip string, failures int, until time.Time) error {
+	return nil
+}
+
+func (m *LoginAttemptModel) Purge(ctx context.Context, before time.Time) (int, error) {
+	return 5, nil
+}
@@ -0,0 +1,132 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// An organization owned by Alice, with Mo as a member
+var mockOrganization = &models.Organization{
+	ID:      1,
+	Name:    "Haiku Club",
+	Created: time.Now(),
+}
+
+var mockMembers = []*models.Membership{
+	{OrgID: 1, OrgName: "Haiku Club", UserID: 1, UserName: "Alice", Email: "alice@example.com", Role: models.OrgRoleOwner, Joined: time.Now()},
+	{OrgID: 1, OrgName: "Haiku Club", UserID: 3, UserName: "Mo", Email: "mod@example.com", Role: models.OrgRoleMember, Joined: time.Now()},
+}
+
+// A pending invitation of Adam to the Haiku Club, which has the token "invitation-token"
+var mockInvitation = &models.Invitation{
+	ID:        1,
+	OrgID:     1,
+	OrgName:   "Haiku Club",
+	Email:     "admin@example.com",
+	Role:      models.OrgRoleMaintainer,
+	InvitedBy: 1,
+	Created:   time.Now(),
+	Expires:   time.Now().AddDate(0, 0, models.InvitationDays),
+}
+
+type OrganizationModel struct{}
+
+func (m *OrganizationModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	return 2, nil
+}
+
+func (m *OrganizationModel) Get(ctx context.Context, id int) (*models.Organization, error) {
+	if id == mockOrganization.ID {
+		return mockOrganization, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *OrganizationModel) ByUser(ctx context.Context, userID int) ([]*models.Membership, error) {
+	members := []*models.Membership{}
+
+	for _, om := range mockMembers {
+		if om.UserID == userID {
+			members = append(members, om)
+		}
+	}
+
+	return members, nil
+}
+
+func (m *OrganizationModel) Members(ctx context.Context, id int) ([]*models.Membership, error) {
+	if id == mockOrganization.ID {
+		return mockMembers, nil
+	}
+
+	return []*models.Membership{}, nil
+}
+
+func (m *OrganizationModel) Member(ctx context.Context, id, userID int) (*models.Membership, error) {
+	for _, om := range mockMembers {
+		if om.OrgID == id && om.UserID == userID {
+			return om, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// SetRole and RemoveMember refuse to change Alice, the only owner of the Haiku Club.
+func (m *OrganizationModel) SetRole(ctx context.Context, id, userID int, role string) error {
+	om, err := m.Member(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if role != models.OrgRoleOwner && om.Role == models.OrgRoleOwner {
+		return models.ErrLastOwner
+	}
+
+	return nil
+}
+
+func (m *OrganizationModel) RemoveMember(ctx context.Context, id, userID int) error {
+	om, err := m.Member(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if om.Role == models.OrgRoleOwner {
+		return models.ErrLastOwner
+	}
+
+	return nil
+}
+
+func (m *OrganizationModel) Invite(ctx context.Context, id int, email, role string, invitedBy int) (string, error) {
+	if id != mockOrganization.ID {
+		return "", models.ErrNoRecord
+	}
+
+	return "new-invitation-token", nil
+}
+
+func (m *OrganizationModel) Invitation(ctx context.Context, token string) (*models.Invitation, error) {
+	if token == "invitation-token" {
+		return mockInvitation, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *OrganizationModel) Invitations(ctx context.Context, id int) ([]*models.Invitation, error) {
+	if id == mockOrganization.ID {
+		return []*models.Invitation{mockInvitation}, nil
+	}
+
+	return []*models.Invitation{}, nil
+}
+
+func (m *OrganizationModel) AcceptInvitation(ctx context.Context, token string, userID int) error {
+	_, err := m.Invitation(ctx, token)
+	return err
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type ReportModel struct{}
+
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reason string) error {
+	switch snippetID {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *ReportModel) Queue(ctx context.Context) ([]*models.ReportedSnippet, error) {
+	rs := &models.ReportedSnippet{
+		SnippetID:    1,
+		Title:        "An old silent pond",
+		Reasons:      []string{"Spam"},
+		LastReported: time.Now(),
+	}
+
+	return []*models.ReportedSnippet{rs}, nil
+}
+
+func (m *ReportModel) Moderate(ctx context.Context, snippetID, moderatorID int, action string) error {
+	switch snippetID {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *ReportModel) Log(ctx context.Context, limit int) ([]*models.ModerationAction, error) {
+	return []*models.ModerationAction{}, nil
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/shtayeb/snippetbox/internal/models"
@@ -8,27 +9,137 @@ import (
 
 var mockSnippet = &models.Snippet{
 	ID:      1,
+	Slug:    "pond-Xq7",
 	Title:   "An old silent pond",
 	Content: "An old silent pond...",
 	Created: time.Now(),
+	Updated: time.Now(),
 	Expires: time.Now(),
+	UserID:  1,
+}
+
+// A snippet owned by Alice that a moderator has hidden
+var mockHiddenSnippet = &models.Snippet{
+	ID:      3,
+	Slug:    "frog_K2m",
+	Title:   "A hidden frog",
+	Content: "A frog jumps into the pond...",
+	Created: time.Now(),
+	Updated: time.Now(),
+	Expires: time.Now(),
+	UserID:  1,
+	Hidden:  true,
+}
+
+// A snippet that Mo created for the Haiku Club organization
+var mockOrgSnippet = &models.Snippet{
+	ID:      4,
+	Slug:    "club-Q8z",
+	Title:   "Over the wintry forest",
+	Content: "Over the wintry forest, winds howl in rage...",
+	Created: time.Now(),
+	Updated: time.Now(),
+	Expires: time.Now(),
+	UserID:  3,
+	OrgID:   1,
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error) {
+	return 2, "crow-B4z", nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, contentType string) error {
+	switch id {
+	case 1, 3, 4:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockHiddenSnippet, nil
+	case 4:
+		return mockOrgSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+// GetLegacy finds the snippets of Alice, which were made before the slugs. The one of
+// the Haiku Club is newer.
+func (m *SnippetModel) GetLegacy(ctx context.Context, id int) (*models.Snippet, error) {
+	if id == mockOrgSnippet.ID {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.Get(ctx, id)
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return mockSnippet, nil
+	case mockHiddenSnippet.Slug:
+		return mockHiddenSnippet, nil
+	case mockOrgSnippet.Slug:
+		return mockOrgSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, limit int) ([]*models.Snippet, error) {
+	if userID == mockSnippet.UserID {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) ByOrg(ctx context.Context, orgID int, limit int) ([]*models.Snippet, error) {
+	if orgID == mockOrgSnippet.OrgID {
+		return []*models.Snippet{mockOrgSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) List(ctx context.Context, search string, limit, offset int) ([]*models.Snippet, int, error) {
+	return []*models.Snippet{mockSnippet, mockHiddenSnippet}, 2, nil
+}
+
+func (m *SnippetModel) After(ctx context.Context, afterID, limit int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	for _, s := range []*models.Snippet{mockSnippet, mockHiddenSnippet} {
+		if s.ID > afterID && len(snippets) < limit {
+			snippets = append(snippets, s)
+		}
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1, 3, 4:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) DeleteExpired(ctx context.Context) (int, error) {
+	return 2, nil
+}
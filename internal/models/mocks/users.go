@@ -1,14 +1,39 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/shtayeb/snippetbox/internal/models"
 )
 
+var mockUsers = map[int]*models.User{
+	1: {
+		ID:      1,
+		Name:    "Alice",
+		Email:   "alice@example.com",
+		Created: time.Now(),
+		Role:    models.RoleUser,
+	},
+	2: {
+		ID:      2,
+		Name:    "Adam",
+		Email:   "admin@example.com",
+		Created: time.Now(),
+		Role:    models.RoleAdmin,
+	},
+	3: {
+		ID:      3,
+		Name:    "Mo",
+		Email:   "mod@example.com",
+		Created: time.Now(),
+		Role:    models.RoleModerator,
+	},
+}
+
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "dupe@example.com":
 		return models.ErrDuplicateEmail
@@ -17,37 +42,39 @@ func (m *UserModel) Insert(name, email, password string) error {
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
-		return 1, nil
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	if password != "pa$$word" {
+		return 0, models.ErrInvalidCredentials
 	}
-	return 0, models.ErrInvalidCredentials
-}
 
-func (m *UserModel) Exists(id int) (bool, error) {
-	switch id {
-	case 1:
-		return true, nil
+	switch email {
+	case "alice@example.com":
+		return 1, nil
+	case "admin@example.com":
+		return 2, nil
+	case "mod@example.com":
+		return 3, nil
+	case "disabled@example.com":
+		return 0, models.ErrAccountDisabled
 	default:
-		return false, nil
+		return 0, models.ErrInvalidCredentials
 	}
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
-	if id == 1 {
-		u := &models.User{
-			ID:      1,
-			Name:    "Alice",
-			Email:   "alice@example.com",
-			Created: time.Now(),
-		}
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	_, ok := mockUsers[id]
+	return ok, nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	if u, ok := mockUsers[id]; ok {
 		return u, nil
 	}
 
 	return nil, models.ErrNoRecord
 }
 
-func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
 	if id == 1 {
 		if currentPassword != "pa$$word" {
 			return models.ErrInvalidCredentials
@@ -57,3 +84,49 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 
 	return models.ErrNoRecord
 }
+
+func (m *UserModel) List(ctx context.Context, search string, limit, offset int) ([]*models.User, int, error) {
+	return []*models.User{mockUsers[1], mockUsers[2], mockUsers[3]}, 3, nil
+}
+
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	if _, ok := mockUsers[id]; ok {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) error {
+	if _, ok := mockUsers[id]; ok {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, u := range mockUsers {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	if _, ok := mockUsers[id]; ok {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) SetPassword(ctx context.Context, id int, password string) error {
+	if _, ok := mockUsers[id]; ok {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
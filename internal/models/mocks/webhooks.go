@@ -0,0 +1,103 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// A webhook of Alice's personal snippets
+var mockWebhook = &models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://chat.example.com/hooks/alice",
+	Events:  []string{models.EventSnippetCreated, models.EventSnippetUpdated},
+	Secret:  "alice-webhook-secret",
+	Created: time.Now(),
+}
+
+// A webhook of the Haiku Club
+var mockOrgWebhook = &models.Webhook{
+	ID:      2,
+	OrgID:   1,
+	URL:     "https://ci.example.com/hooks/club",
+	Events:  models.WebhookEvents,
+	Secret:  "club-webhook-secret",
+	Created: time.Now(),
+}
+
+// A delivery to Alice's webhook that failed, then one that went through
+var mockDeliveries = []*models.Delivery{
+	{ID: 2, WebhookID: 1, Event: models.EventSnippetUpdated, Payload: `{"event":"snippet.updated"}`, StatusCode: 204, Attempts: 1, Created: time.Now(), Updated: time.Now()},
+	{ID: 1, WebhookID: 1, Event: models.EventSnippetCreated, Payload: `{"event":"snippet.created"}`, StatusCode: 503, Attempts: 5, Created: time.Now(), Updated: time.Now()},
+}
+
+type WebhookModel struct{}
+
+func (m *WebhookModel) Insert(ctx context.Context, userID, orgID int, url string, events []string) (int, error) {
+	return 3, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	switch id {
+	case 1:
+		return mockWebhook, nil
+	case 2:
+		return mockOrgWebhook, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *WebhookModel) ByUser(ctx context.Context, userID int) ([]*models.Webhook, error) {
+	if userID == 1 {
+		return []*models.Webhook{mockWebhook}, nil
+	}
+
+	return []*models.Webhook{}, nil
+}
+
+func (m *WebhookModel) ByOrg(ctx context.Context, orgID int) ([]*models.Webhook, error) {
+	if orgID == 1 {
+		return []*models.Webhook{mockOrgWebhook}, nil
+	}
+
+	return []*models.Webhook{}, nil
+}
+
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	_, err := m.Get(ctx, id)
+	return err
+}
+
+// Subscribers finds none, so that the tests of the handlers don't send anything.
+func (m *WebhookModel) Subscribers(ctx context.Context, userID, orgID int, event string) ([]*models.Webhook, error) {
+	return []*models.Webhook{}, nil
+}
+
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event, payload string) (int, error) {
+	return 3, nil
+}
+
+func (m *WebhookModel) RecordAttempt(ctx context.Context, deliveryID, statusCode int, errMsg string) error {
+	return nil
+}
+
+func (m *WebhookModel) Delivery(ctx context.Context, id int) (*models.Delivery, error) {
+	for _, d := range mockDeliveries {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*models.Delivery, error) {
+	if webhookID == 1 {
+		return mockDeliveries, nil
+	}
+
+	return []*models.Delivery{}, nil
+}
@@ -0,0 +1,433 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type OrganizationModelInterface interface {
+	Insert(ctx context.Context, name string, ownerID int) (int, error)
+	Get(ctx context.Context, id int) (*Organization, error)
+	ByUser(ctx context.Context, userID int) ([]*Membership, error)
+	Members(ctx context.Context, id int) ([]*Membership, error)
+	Member(ctx context.Context, id, userID int) (*Membership, error)
+	SetRole(ctx context.Context, id, userID int, role string) error
+	RemoveMember(ctx context.Context, id, userID int) error
+	Invite(ctx context.Context, id int, email, role string, invitedBy int) (string, error)
+	Invitation(ctx context.Context, token string) (*Invitation, error)
+	Invitations(ctx context.Context, id int) ([]*Invitation, error)
+	AcceptInvitation(ctx context.Context, token string, userID int) error
+}
+
+// The roles of the members of an organization
+const (
+	OrgRoleMember     = "member"
+	OrgRoleMaintainer = "maintainer"
+	OrgRoleOwner      = "owner"
+)
+
+// orgRoleRanks orders the roles of an organization like roleRanks does for the site.
+var orgRoleRanks = map[string]int{
+	OrgRoleMember:     1,
+	OrgRoleMaintainer: 2,
+	OrgRoleOwner:      3,
+}
+
+// ValidOrgRole reports whether role is one of the OrgRole constants.
+func ValidOrgRole(role string) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// How long the link of an invitation can be used
+const InvitationDays = 7
+
+// An invitation token holds 32 random bytes, as many as a session token
+const invitationTokenBytes = 32
+
+// Organization is a group of users that share the snippets created for it.
+type Organization struct {
+	ID      int
+	Name    string
+	Created time.Time
+}
+
+// Membership is the place of a user in an organization, with the names of both.
+type Membership struct {
+	OrgID    int
+	OrgName  string
+	UserID   int
+	UserName string
+	Email    string
+	// OrgRoleMember, OrgRoleMaintainer or OrgRoleOwner
+	Role   string
+	Joined time.Time
+}
+
+// HasRole reports whether the member has the given role or a higher one.
+func (m *Membership) HasRole(role string) bool {
+	rank, ok := orgRoleRanks[role]
+	return ok && orgRoleRanks[m.Role] >= rank
+}
+
+// Invitation is a pending invitation to join an organization, sent to an email address
+// as a link with a token. Only the hash of the token is stored.
+type Invitation struct {
+	ID      int
+	OrgID   int
+	OrgName string
+	Email   string
+	// The role the invited user gets on accepting
+	Role string
+	// ID of the member who sent the invitation, zero if the account is gone
+	InvitedBy int
+	Created   time.Time
+	Expires   time.Time
+}
+
+// NewInvitationToken returns a random token for the link of an invitation, and the
+// hash of it that is stored.
+func NewInvitationToken() (string, []byte, error) {
+	b := make([]byte, invitationTokenBytes)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashInvitationToken(token), nil
+}
+
+// HashInvitationToken returns the hash under which the token of an invitation is stored.
+func HashInvitationToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+type OrganizationModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Insert creates an organization with ownerID as its first owner.
+func (m *OrganizationModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO organizations (name, created) VALUES ($1, NOW()) RETURNING id`, name).Scan(&id)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role, created)
+	VALUES ($1, $2, $3, NOW())`, id, ownerID, OrgRoleOwner)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	return id, ContextError(ctx, tx.Commit())
+}
+
+func (m *OrganizationModel) Get(ctx context.Context, id int) (*Organization, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, name, created FROM organizations WHERE id = $1`
+
+	o := &Organization{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&o.ID, &o.Name, &o.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, ContextError(ctx, err)
+	}
+
+	return o, nil
+}
+
+// ByUser returns the memberships of a user, by the name of the organization.
+func (m *OrganizationModel) ByUser(ctx context.Context, userID int) ([]*Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.user_id = $1 ORDER BY o.name, o.id`
+
+	return m.queryMembers(ctx, stmt, userID)
+}
+
+// Members returns the members of an organization, by name.
+func (m *OrganizationModel) Members(ctx context.Context, id int) ([]*Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.org_id = $1 ORDER BY u.name, u.id`
+
+	return m.queryMembers(ctx, stmt, id)
+}
+
+// Member returns the membership of a user in an organization, or ErrNoRecord if the
+// user isn't a member.
+func (m *OrganizationModel) Member(ctx context.Context, id, userID int) (*Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.org_id = $1 AND om.user_id = $2`
+
+	members, err := m.queryMembers(ctx, stmt, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return members[0], nil
+}
+
+// SetRole changes the role of a member. It returns ErrLastOwner if that would take the
+// role away from the only owner of the organization.
+func (m *OrganizationModel) SetRole(ctx context.Context, id, userID int, role string) error {
+	stmt := `UPDATE organization_members SET role = $3 WHERE org_id = $1 AND user_id = $2`
+
+	if role == OrgRoleOwner {
+		ctx, cancel := WithTimeout(ctx, m.Timeout)
+		defer cancel()
+
+		return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id, userID, role)))
+	}
+
+	return m.changeOwner(ctx, id, userID, stmt, id, userID, role)
+}
+
+// RemoveMember takes a user out of an organization. The snippets they created for it
+// stay with the organization. It returns ErrLastOwner for its only owner.
+func (m *OrganizationModel) RemoveMember(ctx context.Context, id, userID int) error {
+	stmt := `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`
+
+	return m.changeOwner(ctx, id, userID, stmt, id, userID)
+}
+
+// changeOwner runs stmt, which demotes or removes userID, unless userID is the only
+// owner of the organization. The owners are locked until it is done, so that two
+// concurrent changes can't both see another owner and leave none.
+func (m *OrganizationModel) changeOwner(ctx context.Context, id, userID int, stmt string, args ...any) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM organization_members
+	WHERE org_id = $1 AND role = $2 FOR UPDATE`, id, OrgRoleOwner)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var owners []int
+
+	for rows.Next() {
+		var owner int
+
+		err = rows.Scan(&owner)
+		if err != nil {
+			return ContextError(ctx, err)
+		}
+
+		owners = append(owners, owner)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ContextError(ctx, err)
+	}
+
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+
+	err = expectOneRow(tx.ExecContext(ctx, stmt, args...))
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	return ContextError(ctx, tx.Commit())
+}
+
+// Invite invites email to join an organization with the given role and returns the
+// token for the link of the invitation. Inviting an address again replaces its
+// pending invitation, so only the latest link works.
+func (m *OrganizationModel) Invite(ctx context.Context, id int, email, role string, invitedBy int) (string, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	token, hash, err := NewInvitationToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO organization_invitations (org_id, email, role, token_hash, invited_by, created, expires)
+	VALUES ($1, $2, $3, $4, $5, NOW(), NOW() + ($6 || ' days')::INTERVAL)
+	ON CONFLICT (org_id, email) DO UPDATE SET role = EXCLUDED.role, token_hash = EXCLUDED.token_hash,
+	invited_by = EXCLUDED.invited_by, created = EXCLUDED.created, expires = EXCLUDED.expires`
+
+	_, err = m.DB.ExecContext(ctx, stmt, id, email, role, hash, invitedBy, InvitationDays)
+	if err != nil {
+		var pgSQLError *pq.Error
+		// The organization doesn't exist
+		if errors.As(err, &pgSQLError) && pgSQLError.Code == "23503" {
+			return "", ErrNoRecord
+		}
+		return "", ContextError(ctx, err)
+	}
+
+	return token, nil
+}
+
+// Invitation returns the pending invitation with the given token, or ErrNoRecord if
+// there is none or it has expired.
+func (m *OrganizationModel) Invitation(ctx context.Context, token string) (*Invitation, error) {
+	stmt := `SELECT i.id, i.org_id, o.name, i.email, i.role, COALESCE(i.invited_by, 0), i.created, i.expires
+	FROM organization_invitations i JOIN organizations o ON o.id = i.org_id
+	WHERE i.token_hash = $1 AND i.expires > now()`
+
+	invitations, err := m.queryInvitations(ctx, stmt, HashInvitationToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invitations) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return invitations[0], nil
+}
+
+// Invitations returns the pending invitations of an organization, by email.
+func (m *OrganizationModel) Invitations(ctx context.Context, id int) ([]*Invitation, error) {
+	stmt := `SELECT i.id, i.org_id, o.name, i.email, i.role, COALESCE(i.invited_by, 0), i.created, i.expires
+	FROM organization_invitations i JOIN organizations o ON o.id = i.org_id
+	WHERE i.org_id = $1 AND i.expires > now() ORDER BY i.email`
+
+	return m.queryInvitations(ctx, stmt, id)
+}
+
+// AcceptInvitation makes userID a member of the organization of the invitation with
+// the given token, and uses the invitation up. A user who is already a member keeps
+// their role. It returns ErrNoRecord if there is no such pending invitation.
+func (m *OrganizationModel) AcceptInvitation(ctx context.Context, token string, userID int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var id, orgID int
+	var role string
+
+	// Lock the invitation so that it can only be accepted once
+	err = tx.QueryRowContext(ctx, `SELECT id, org_id, role FROM organization_invitations
+	WHERE token_hash = $1 AND expires > now() FOR UPDATE`, HashInvitationToken(token)).Scan(&id, &orgID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role, created)
+	VALUES ($1, $2, $3, NOW()) ON CONFLICT DO NOTHING`, orgID, userID, role)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM organization_invitations WHERE id = $1`, id)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	return ContextError(ctx, tx.Commit())
+}
+
+func (m *OrganizationModel) queryMembers(ctx context.Context, stmt string, args ...any) ([]*Membership, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	members := []*Membership{}
+
+	for rows.Next() {
+		om := &Membership{}
+
+		err = rows.Scan(&om.OrgID, &om.OrgName, &om.UserID, &om.UserName, &om.Email, &om.Role, &om.Joined)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		members = append(members, om)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return members, nil
+}
+
+func (m *OrganizationModel) queryInvitations(ctx context.Context, stmt string, args ...any) ([]*Invitation, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+
+	for rows.Next() {
+		i := &Invitation{}
+
+		err = rows.Scan(&i.ID, &i.OrgID, &i.OrgName, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expires)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return invitations, nil
+}
@@ -0,0 +1,148 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestOrganizationModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.Organizations
+
+		assert.NilError(t, store.Users.Insert(ctx, "Bob Smith", "bob@example.com", "pa$$word"))
+		bob, err := store.Users.GetByEmail(ctx, "bob@example.com")
+		assert.NilError(t, err)
+
+		id, err := m.Insert(ctx, "Haiku Club", 1)
+		assert.NilError(t, err)
+
+		o, err := m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, o.Name, "Haiku Club")
+
+		// The creator is the first owner
+		om, err := m.Member(ctx, id, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, om.Role, models.OrgRoleOwner)
+		assert.Equal(t, om.UserName, "Alice Jones")
+		assert.Equal(t, om.OrgName, "Haiku Club")
+		assert.Equal(t, om.HasRole(models.OrgRoleMaintainer), true)
+
+		_, err = m.Member(ctx, id, bob.ID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		_, err = m.Invite(ctx, id, "bob@example.com", models.OrgRoleMaintainer, 1)
+		assert.NilError(t, err)
+
+		// Inviting again replaces the first link
+		token, err := m.Invite(ctx, id, "bob@example.com", models.OrgRoleMember, 1)
+		assert.NilError(t, err)
+
+		_, err = m.Invite(ctx, 99, "bob@example.com", models.OrgRoleMember, 1)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		invitations, err := m.Invitations(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, len(invitations), 1)
+		assert.Equal(t, invitations[0].Role, models.OrgRoleMember)
+
+		i, err := m.Invitation(ctx, token)
+		assert.NilError(t, err)
+		assert.Equal(t, i.OrgID, id)
+		assert.Equal(t, i.OrgName, "Haiku Club")
+		assert.Equal(t, i.Email, "bob@example.com")
+		assert.Equal(t, i.InvitedBy, 1)
+
+		_, err = m.Invitation(ctx, "not-a-token")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		assert.NilError(t, m.AcceptInvitation(ctx, token, bob.ID))
+
+		// The link only works once
+		err = m.AcceptInvitation(ctx, token, bob.ID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		invitations, err = m.Invitations(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, len(invitations), 0)
+
+		om, err = m.Member(ctx, id, bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, om.Role, models.OrgRoleMember)
+		assert.Equal(t, om.HasRole(models.OrgRoleMaintainer), false)
+
+		// Members are listed by name
+		members, err := m.Members(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, len(members), 2)
+		assert.Equal(t, members[0].UserID, 1)
+		assert.Equal(t, members[1].Email, "bob@example.com")
+
+		other, err := m.Insert(ctx, "Art Club", bob.ID)
+		assert.NilError(t, err)
+
+		memberships, err := m.ByUser(ctx, bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(memberships), 2)
+		assert.Equal(t, memberships[0].OrgID, other)
+		assert.Equal(t, memberships[1].Role, models.OrgRoleMember)
+
+		assert.NilError(t, m.SetRole(ctx, id, bob.ID, models.OrgRoleMaintainer))
+
+		om, err = m.Member(ctx, id, bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, om.Role, models.OrgRoleMaintainer)
+
+		// The only owner can neither give up the role nor leave, until there is another one
+		err = m.SetRole(ctx, id, 1, models.OrgRoleMember)
+		assert.Equal(t, errors.Is(err, models.ErrLastOwner), true)
+
+		err = m.RemoveMember(ctx, id, 1)
+		assert.Equal(t, errors.Is(err, models.ErrLastOwner), true)
+
+		assert.NilError(t, m.SetRole(ctx, id, bob.ID, models.OrgRoleOwner))
+		assert.NilError(t, m.SetRole(ctx, id, 1, models.OrgRoleMaintainer))
+
+		err = m.SetRole(ctx, id, bob.ID, models.OrgRoleMaintainer)
+		assert.Equal(t, errors.Is(err, models.ErrLastOwner), true)
+
+		assert.NilError(t, m.SetRole(ctx, id, 1, models.OrgRoleOwner))
+		assert.NilError(t, m.SetRole(ctx, id, bob.ID, models.OrgRoleMaintainer))
+
+		// The snippets of an organization are listed apart from the personal ones
+		orgSnippet, _, err := store.Snippets.Insert(ctx, bob.ID, id, "Club rules", "No rhymes", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+		_, _, err = store.Snippets.Insert(ctx, bob.ID, 0, "My own", "A rhyme", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+
+		s, err := store.Snippets.Get(ctx, orgSnippet)
+		assert.NilError(t, err)
+		assert.Equal(t, s.OrgID, id)
+		assert.Equal(t, s.UserID, bob.ID)
+
+		snippets, err := store.Snippets.ByOrg(ctx, id, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 1)
+		assert.Equal(t, snippets[0].ID, orgSnippet)
+
+		assert.NilError(t, m.RemoveMember(ctx, id, bob.ID))
+
+		err = m.RemoveMember(ctx, id, bob.ID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.SetRole(ctx, id, bob.ID, models.OrgRoleOwner)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// The snippets a former member created stay with the organization
+		snippets, err = store.Snippets.ByOrg(ctx, id, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 1)
+	})
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ModerationHide    = "hide"
+	ModerationRestore = "restore"
+	ModerationDelete  = "delete"
+)
+
+type ReportModelInterface interface {
+	Insert(ctx context.Context, snippetID, reporterID int, reason string) error
+	Queue(ctx context.Context) ([]*ReportedSnippet, error)
+	Moderate(ctx context.Context, snippetID, moderatorID int, action string) error
+	Log(ctx context.Context, limit int) ([]*ModerationAction, error)
+}
+
+// ReportedSnippet is an entry in the moderation queue: a snippet together with
+// the reports against it that no moderator has acted on yet.
+type ReportedSnippet struct {
+	SnippetID    int
+	Slug         string
+	Title        string
+	Hidden       bool
+	Reasons      []string
+	LastReported time.Time
+}
+
+type ModerationAction struct {
+	ID            int
+	SnippetID     int
+	ModeratorName string
+	Action        string
+	Created       time.Time
+}
+
+type ReportModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reason string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO snippet_reports (snippet_id, reporter_id, reason, created)
+	VALUES ($1, $2, $3, NOW())`
+
+	_, err := m.DB.ExecContext(ctx, stmt, snippetID, reporterID, reason)
+	if err != nil {
+		var pgSQLError *pq.Error
+		// PostgreSQL foreign key violation error code is "23503".
+		if errors.As(err, &pgSQLError) && pgSQLError.Code == "23503" {
+			return ErrNoRecord
+		}
+		return ContextError(ctx, err)
+	}
+
+	return nil
+}
+
+// Queue returns the snippets with open reports, most recently reported first.
+func (m *ReportModel) Queue(ctx context.Context) ([]*ReportedSnippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.slug, s.title, s.hidden, array_agg(r.reason ORDER BY r.created), MAX(r.created)
+	FROM snippet_reports r JOIN snippets s ON s.id = r.snippet_id
+	WHERE r.resolved IS NULL
+	GROUP BY s.id ORDER BY MAX(r.created) DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	queue := []*ReportedSnippet{}
+
+	for rows.Next() {
+		rs := &ReportedSnippet{}
+
+		err = rows.Scan(&rs.SnippetID, &rs.Slug, &rs.Title, &rs.Hidden, (*pq.StringArray)(&rs.Reasons), &rs.LastReported)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		queue = append(queue, rs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return queue, nil
+}
+
+// Moderate hides, restores or deletes a snippet, closes its open reports and logs
+// the action, all in one transaction.
+func (m *ReportModel) Moderate(ctx context.Context, snippetID, moderatorID int, action string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var stmt string
+
+	switch action {
+	case ModerationHide:
+		stmt = `UPDATE snippets SET hidden = true WHERE id = $1`
+	case ModerationRestore:
+		stmt = `UPDATE snippets SET hidden = false WHERE id = $1`
+	case ModerationDelete:
+		stmt = `DELETE FROM snippets WHERE id = $1`
+	default:
+		return fmt.Errorf("models: unknown moderation action %q", action)
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = expectOneRow(tx.ExecContext(ctx, stmt, snippetID))
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	// Deleting the snippet already removed its reports through the foreign key
+	if action != ModerationDelete {
+		stmt = `UPDATE snippet_reports SET resolved = NOW() WHERE snippet_id = $1 AND resolved IS NULL`
+		_, err = tx.ExecContext(ctx, stmt, snippetID)
+		if err != nil {
+			return ContextError(ctx, err)
+		}
+	}
+
+	stmt = `INSERT INTO moderation_actions (snippet_id, moderator_id, action, created)
+	VALUES ($1, $2, $3, NOW())`
+	_, err = tx.ExecContext(ctx, stmt, snippetID, moderatorID, action)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+
+	return ContextError(ctx, tx.Commit())
+}
+
+// Log returns the most recent moderation actions.
+func (m *ReportModel) Log(ctx context.Context, limit int) ([]*ModerationAction, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT a.id, a.snippet_id, COALESCE(u.name, ''), a.action, a.created
+	FROM moderation_actions a LEFT JOIN users u ON u.id = a.moderator_id
+	ORDER BY a.id DESC LIMIT $1`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	actions := []*ModerationAction{}
+
+	for rows.Next() {
+		a := &ModerationAction{}
+
+		err = rows.Scan(&a.ID, &a.SnippetID, &a.ModeratorName, &a.Action, &a.Created)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		actions = append(actions, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestReportModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.Reports
+
+		id, slug, err := store.Snippets.Insert(ctx, 1, 0, "An old silent pond", "An old silent pond...", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+
+		assert.NilError(t, m.Insert(ctx, id, 1, "Spam"))
+		assert.NilError(t, m.Insert(ctx, id, 1, "Off topic, with a \"quote\""))
+
+		err = m.Insert(ctx, 99, 1, "Spam")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		queue, err := m.Queue(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(queue), 1)
+		assert.Equal(t, queue[0].SnippetID, id)
+		assert.Equal(t, queue[0].Slug, slug)
+		assert.Equal(t, len(queue[0].Reasons), 2)
+		assert.Equal(t, queue[0].Reasons[1], "Off topic, with a \"quote\"")
+		assert.Equal(t, time.Since(queue[0].LastReported) < time.Hour, true)
+
+		assert.NilError(t, m.Moderate(ctx, id, 1, models.ModerationHide))
+
+		// Acting on a snippet resolves its reports
+		queue, err = m.Queue(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(queue), 0)
+
+		assert.NilError(t, m.Moderate(ctx, id, 1, models.ModerationDelete))
+
+		err = m.Moderate(ctx, id, 1, models.ModerationRestore)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		log, err := m.Log(ctx, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(log), 2)
+		assert.Equal(t, log[0].Action, models.ModerationDelete)
+		assert.Equal(t, log[0].SnippetID, id)
+		assert.Equal(t, log[0].ModeratorName, "Alice Jones")
+	})
+}
+
+func TestLoginAttemptModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.LoginAttempts
+		since := time.Now().Add(-time.Hour)
+
+		assert.NilError(t, m.RecordFailure(ctx, "Bob@example.com", "192.0.2.1"))
+		assert.NilError(t, m.RecordFailure(ctx, "bob@example.com", "192.0.2.2"))
+		assert.NilError(t, m.RecordFailure(ctx, "eve@example.com", "192.0.2.1"))
+
+		f, err := m.Failures(ctx, "BOB@example.com", "192.0.2.1", since)
+		assert.NilError(t, err)
+		assert.Equal(t, f.Email, 2)
+		assert.Equal(t, f.IP, 2)
+		assert.Equal(t, time.Since(f.EmailLast) < time.Hour, true)
+		assert.Equal(t, f.IPLast.Before(f.EmailLast), false)
+
+		f, err = m.Failures(ctx, "bob@example.com", "192.0.2.1", time.Now().Add(time.Minute))
+		assert.NilError(t, err)
+		assert.Equal(t, f.Email, 0)
+		assert.Equal(t, f.EmailLast.IsZero(), true)
+
+		assert.NilError(t, m.Reset(ctx, "bob@example.com"))
+
+		f, err = m.Failures(ctx, "bob@example.com", "192.0.2.1", since)
+		assert.NilError(t, err)
+		assert.Equal(t, f.Email, 0)
+		assert.Equal(t, f.EmailLast.IsZero(), true)
+		assert.Equal(t, f.IP, 1)
+		assert.Equal(t, f.IPLast.IsZero(), false)
+
+		assert.NilError(t, m.RecordLockout(ctx, "eve@example.com", "192.0.2.1", 10, time.Now().Add(time.Minute)))
+
+		n, err := m.Purge(ctx, time.Now().Add(time.Hour))
+		assert.NilError(t, err)
+		assert.Equal(t, n, 2)
+	})
+}
@@ -1,15 +1,30 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error)
+	Update(ctx context.Context, id int, title string, content string, contentType string) error
+	Get(ctx context.Context, id int) (*Snippet, error)
+	GetBySlug(ctx context.Context, slug string) (*Snippet, error)
+	GetLegacy(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context) ([]*Snippet, error)
+	ByUser(ctx context.Context, userID int, limit int) ([]*Snippet, error)
+	ByOrg(ctx context.Context, orgID int, limit int) ([]*Snippet, error)
+	List(ctx context.Context, search string, limit, offset int) ([]*Snippet, int, error)
+	After(ctx context.Context, afterID, limit int) ([]*Snippet, error)
+	Delete(ctx context.Context, id int) error
+	DeleteExpired(ctx context.Context) (int, error)
 }
 
 // the Exec, Query and QueryRow uses prepared statement for each query
@@ -18,41 +33,130 @@ type SnippetModelInterface interface {
 // If the pool is not used them the statement is prepared again in another pool
 // consider the pros and cons before doing any performance optimization
 
+// The content types of a snippet. Text is shown as it is, markdown is rendered to HTML.
+const (
+	ContentTypeText     = "text"
+	ContentTypeMarkdown = "markdown"
+)
+
 type Snippet struct {
-	ID      int
+	ID int
+	// The random part of the public address of the snippet, /s/<slug>, which unlike the
+	// ID doesn't tell how many snippets there are
+	Slug    string
 	Title   string
 	Content string
-	Created time.Time
+	// How Content is shown, ContentTypeText or ContentTypeMarkdown
+	ContentType string
+	Created     time.Time
+	// When the snippet was last edited, Created until it is
+	Updated time.Time
 	Expires time.Time
+	// ID of the user who created the snippet, zero if the account is gone
+	UserID int
+	// Hidden snippets were taken down by a moderator and are only shown to their owner
+	Hidden bool
+	// ID of the organization that shares the snippet among its members, zero for a
+	// snippet of UserID alone
+	OrgID int
+}
+
+const (
+	// A slug holds 6 random bytes, which base64 turns into 8 URL-safe characters
+	slugBytes = 6
+	// Two snippets getting the same slug is already unlikely, five in a row means
+	// something else is wrong
+	slugAttempts = 5
+)
+
+// NewSlug returns a random slug of 8 URL-safe characters.
+func NewSlug() (string, error) {
+	b := make([]byte, slugBytes)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// InsertWithSlug runs insert with a new slug each time it returns ErrDuplicateSlug, up to
+// slugAttempts times. It returns the ID of the new snippet and the slug it was given.
+func InsertWithSlug(insert func(slug string) (int, error)) (int, string, error) {
+	for range slugAttempts {
+		slug, err := NewSlug()
+		if err != nil {
+			return 0, "", err
+		}
+
+		id, err := insert(slug)
+		if errors.Is(err, ErrDuplicateSlug) {
+			continue
+		}
+		if err != nil {
+			return 0, "", err
+		}
+
+		return id, slug, nil
+	}
+
+	return 0, "", ErrDuplicateSlug
 }
 
 // Define a SnippetModel type which wraps a sql.DB connection pool
 type SnippetModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-// This will insert a new snippet into the database
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires) 
-	VALUES ($1, $2, NOW(), NOW() + ($3 || ' days')::INTERVAL) RETURNING id`
+// This will insert a new snippet into the database, with a new slug, and return its
+// id and slug. An orgID of zero makes a snippet of the user alone.
+func (m *SnippetModel) Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	var id int
-	err := m.DB.QueryRow(stmt, title, content, expires).Scan(&id)
-	if err != nil {
-		return 0, err
-	}
+	stmt := `INSERT INTO snippets (title, content, content_type, created, updated, expires, user_id, slug, org_id) 
+	VALUES ($1, $2, $3, NOW(), NOW(), NOW() + ($4 || ' days')::INTERVAL, $5, $6, NULLIF($7, 0)) RETURNING id`
+
+	return InsertWithSlug(func(slug string) (int, error) {
+		var id int
+		err := m.DB.QueryRowContext(ctx, stmt, title, content, contentType, expires, userID, slug, orgID).Scan(&id)
+		if err != nil {
+			var pgSQLError *pq.Error
+			// PostgreSQL unique constraint violation error code is "23505".
+			if errors.As(err, &pgSQLError) && pgSQLError.Code == "23505" && strings.Contains(pgSQLError.Message, "snippets_uc_slug") {
+				return 0, ErrDuplicateSlug
+			}
+			return 0, ContextError(ctx, err)
+		}
+
+		return id, nil
+	})
+}
+
+// Update changes the title and content of a snippet that hasn't expired and dates it
+// as updated now. Its slug, owner and expiry stay as they are.
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, contentType string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE snippets SET title = $2, content = $3, content_type = $4, updated = now() WHERE id = $1 AND expires > now()`
 
-	// the `id` type int64, so convert it to an int type
-	return int(id), nil
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id, title, content, contentType)))
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
+// This will return a specific snippet based on its id. Hidden snippets are returned
+// too, it is up to the caller to only show them to their owner and to moderators.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
 	WHERE expires > now() AND id = $1`
 
 	// This returns a pointer to a sql.Row object which holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRowContext(ctx, stmt, id)
 	// initialze a pointer to a new zeroed struct
 	s := &Snippet{}
 
@@ -61,7 +165,7 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	// to row.Scan are *pointers* to the place you want to copy the data into,
 	// and the number of arguments must be exactly the same as the number of
 	// columns returned by your statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -69,7 +173,7 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
 		} else {
-			return nil, err
+			return nil, ContextError(ctx, err)
 		}
 
 	}
@@ -77,15 +181,61 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > now() ORDER BY id DESC LIMIT 10`
+// GetBySlug returns the snippet with the given slug, like Get.
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > now() AND slug = $1`
+
+	s := &Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, slug).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+// GetLegacy is Get for the snippets made before the slugs, the only ones whose numeric
+// address is still served. It returns ErrNoRecord for the others.
+func (m *SnippetModel) GetLegacy(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > now() AND id = $1 AND legacy`
+
+	s := &Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+// This will return the 10 most recently created snippets that haven't been hidden.
+func (m *SnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > now() AND NOT hidden ORDER BY id DESC LIMIT 10`
 
 	// This returns a sql.Rows resultset containing the result
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, stmt)
 	if err != nil {
-		return nil, err
+		return nil, ContextError(ctx, err)
 	}
 	// We defer rows.Close() to ensure the sql.Rows resultset is
 	// always properly closed before the Latest() method returns. This defer
@@ -109,9 +259,9 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		// must be pointers to the place you want to copy the data into, and the
 		// number of arguments must be exactly the same as the number of
 		// columns returned by your statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
 		if err != nil {
-			return nil, err
+			return nil, ContextError(ctx, err)
 		}
 		// Append it to the slice of snippets.
 		snippets = append(snippets, s)
@@ -122,9 +272,176 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// call this - don't assume that a successful iteration was completed
 	// over the whole resultset.
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, ContextError(ctx, err)
 	}
 
 	// If everything went OK then return the Snippets slice.
 	return snippets, nil
 }
+
+// ByUser returns the most recently created snippets of a user that haven't expired
+// or been hidden, newest first.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, limit int) ([]*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE user_id = $1 AND expires > now() AND NOT hidden ORDER BY id DESC LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, limit)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// ByOrg returns the most recently created snippets of an organization, like ByUser.
+func (m *SnippetModel) ByOrg(ctx context.Context, orgID int, limit int) ([]*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE org_id = $1 AND expires > now() AND NOT hidden ORDER BY id DESC LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, orgID, limit)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// List returns a page of snippets, expired ones included, whose title or content
+// contains search, along with the total number of matching snippets.
+func (m *SnippetModel) List(ctx context.Context, search string, limit, offset int) ([]*Snippet, int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT COUNT(*) OVER(), id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE $1 = '' OR title ILIKE '%' || $1 || '%' OR content ILIKE '%' || $1 || '%'
+	ORDER BY id DESC LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, search, limit, offset)
+	if err != nil {
+		return nil, 0, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	total := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&total, &s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, 0, ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, ContextError(ctx, err)
+	}
+
+	return snippets, total, nil
+}
+
+// After returns up to limit snippets, expired and hidden ones included, whose ID is above
+// afterID, oldest first. Paging with the ID of the last snippet of each page goes through
+// every snippet once, even while others are added or deleted.
+func (m *SnippetModel) After(ctx context.Context, afterID, limit int) ([]*Snippet, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE id > $1 ORDER BY id LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, afterID, limit)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE id = $1`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+// DeleteExpired removes every expired snippet and returns how many there were.
+func (m *SnippetModel) DeleteExpired(ctx context.Context) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE expires <= now()`
+
+	result, err := m.DB.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), ContextError(ctx, err)
+}
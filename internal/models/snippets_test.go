@@ -0,0 +1,163 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+var slugRX = regexp.MustCompile(`^[A-Za-z0-9_-]{8}$`)
+
+func TestSnippetModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.Snippets
+
+		id, slug, err := m.Insert(ctx, 1, 0, "An old silent pond", "An old *silent* pond...", models.ContentTypeMarkdown, 7)
+		assert.NilError(t, err)
+		assert.Equal(t, len(slug), 8)
+
+		s, err := m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Slug, slug)
+		assert.Equal(t, s.Title, "An old silent pond")
+		assert.Equal(t, s.ContentType, models.ContentTypeMarkdown)
+		assert.Equal(t, s.UserID, 1)
+		assert.Equal(t, s.Hidden, false)
+		assert.Equal(t, s.Updated.Equal(s.Created), true)
+		// The expiry is worked out by each backend, it must still be 7 days
+		assert.Equal(t, s.Expires.Sub(s.Created).Round(time.Hour), 7*24*time.Hour)
+
+		// A snippet that expires straight away can't be viewed but is still listed
+		s, err = m.GetBySlug(ctx, slug)
+		assert.NilError(t, err)
+		assert.Equal(t, s.ID, id)
+
+		_, err = m.GetBySlug(ctx, "missing1")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// Only snippets made before the slugs keep their numeric address
+		_, err = m.GetLegacy(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		expiredID, expiredSlug, err := m.Insert(ctx, 1, 0, "A frog jumps", "Into the pond", models.ContentTypeText, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, expiredSlug != slug, true)
+
+		_, err = m.Get(ctx, expiredID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		_, err = m.GetBySlug(ctx, expiredSlug)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// An edit keeps the slug and expiry, and expired snippets can't be edited
+		assert.NilError(t, m.Update(ctx, id, "An old pond", "A frog jumps in", models.ContentTypeText))
+
+		s, err = m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "An old pond")
+		assert.Equal(t, s.Content, "A frog jumps in")
+		assert.Equal(t, s.ContentType, models.ContentTypeText)
+		assert.Equal(t, s.Slug, slug)
+		assert.Equal(t, s.OrgID, 0)
+		assert.Equal(t, s.Updated.After(s.Created), true)
+
+		err = m.Update(ctx, expiredID, "A frog", "Jumps", models.ContentTypeText)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		hiddenID, _, err := m.Insert(ctx, 1, 0, "Splash", "Silence again", models.ContentTypeText, 7)
+		assert.NilError(t, err)
+		assert.NilError(t, store.Reports.Moderate(ctx, hiddenID, 1, models.ModerationHide))
+
+		latest, err := m.Latest(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(latest), 1)
+		assert.Equal(t, latest[0].ID, id)
+		assert.Equal(t, latest[0].Slug, slug)
+
+		snippets, total, err := m.List(ctx, "POND", 10, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, total, 2)
+		assert.Equal(t, snippets[0].ID, expiredID)
+
+		snippets, total, err = m.List(ctx, "", 1, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, total, 3)
+		assert.Equal(t, snippets[0].ID, hiddenID)
+		assert.Equal(t, snippets[0].Hidden, true)
+
+		// Paging by ID goes oldest first through every snippet, expired and hidden ones included
+		after, err := m.After(ctx, 0, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, len(after), 2)
+		assert.Equal(t, after[0].ID, id)
+		assert.Equal(t, after[1].ID, expiredID)
+
+		after, err = m.After(ctx, after[1].ID, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, len(after), 1)
+		assert.Equal(t, after[0].ID, hiddenID)
+
+		// Only the visible snippets of the user, the expired and hidden ones are left out
+		byUser, err := m.ByUser(ctx, 1, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(byUser), 1)
+		assert.Equal(t, byUser[0].ID, id)
+
+		byUser, err = m.ByUser(ctx, 2, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(byUser), 0)
+
+		n, err := m.DeleteExpired(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+
+		assert.NilError(t, m.Delete(ctx, id))
+
+		err = m.Delete(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
+
+func TestInsertWithSlug(t *testing.T) {
+	var slugs []string
+
+	// The first two slugs are taken
+	id, slug, err := models.InsertWithSlug(func(slug string) (int, error) {
+		slugs = append(slugs, slug)
+		if len(slugs) < 3 {
+			return 0, models.ErrDuplicateSlug
+		}
+		return 7, nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, id, 7)
+	assert.Equal(t, slug, slugs[2])
+	assert.Equal(t, slugs[0] != slugs[1], true)
+
+	for _, s := range slugs {
+		assert.Equal(t, slugRX.MatchString(s), true)
+	}
+
+	// Other errors are returned straight away
+	calls := 0
+	_, _, err = models.InsertWithSlug(func(slug string) (int, error) {
+		calls++
+		return 0, models.ErrNoRecord
+	})
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	assert.Equal(t, calls, 1)
+
+	// And a slug that is always taken gives up eventually
+	_, _, err = models.InsertWithSlug(func(slug string) (int, error) {
+		return 0, models.ErrDuplicateSlug
+	})
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateSlug), true)
+}
@@ -0,0 +1,237 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type CollectionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO collections (user_id, title, description, visibility, created) VALUES (?, ?, ?, ?, ?)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, title, description, visibility, now())
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	return int(id), nil
+}
+
+func (m *CollectionModel) Get(ctx context.Context, id int) (*models.Collection, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, title, description, visibility, created FROM collections WHERE id = ?`
+
+	c := &models.Collection{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&c.ID, &c.UserID, &c.Title, &c.Description, &c.Visibility, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE collections SET title = ?, description = ?, visibility = ? WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, title, description, visibility, id)))
+}
+
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM collections WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*models.Collection, error) {
+	stmt := `SELECT id, user_id, title, description, visibility, created FROM collections
+	WHERE user_id = ? ORDER BY title, id`
+
+	return m.query(ctx, stmt, userID)
+}
+
+func (m *CollectionModel) BySnippet(ctx context.Context, snippetID int) ([]*models.Collection, error) {
+	stmt := `SELECT c.id, c.user_id, c.title, c.description, c.visibility, c.created
+	FROM collections c JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE cs.snippet_id = ? ORDER BY c.title, c.id`
+
+	return m.query(ctx, stmt, snippetID)
+}
+
+func (m *CollectionModel) query(ctx context.Context, stmt string, args ...any) ([]*models.Collection, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+
+	for rows.Next() {
+		c := &models.Collection{}
+
+		err = rows.Scan(&c.ID, &c.UserID, &c.Title, &c.Description, &c.Visibility, &c.Created)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return collections, nil
+}
+
+func (m *CollectionModel) Snippets(ctx context.Context, id int) ([]*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.content_type, s.created, s.updated, s.expires, COALESCE(s.user_id, 0), s.hidden, COALESCE(s.org_id, 0)
+	FROM snippets s JOIN collection_snippets cs ON cs.snippet_id = s.id
+	WHERE cs.collection_id = ? AND s.expires > ? ORDER BY cs.position`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id, now())
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// AddSnippet needs the WHERE clause of the SELECT, without it SQLite would take ON for
+// the start of a join.
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?1, ?2, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?1
+	ON CONFLICT DO NOTHING`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, snippetID)
+	if err != nil {
+		if isConstraintError(err, sqlite3.ErrConstraintForeignKey) {
+			return models.ErrNoRecord
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id, snippetID)))
+}
+
+// MoveSnippet relies on the immediate transactions of the connection to keep
+// concurrent moves apart, SQLite has no SELECT ... FOR UPDATE.
+func (m *CollectionModel) MoveSnippet(ctx context.Context, id, snippetID int, up bool) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var position int
+
+	stmt := `SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+
+	err = tx.QueryRowContext(ctx, stmt, id, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	stmt = `SELECT cs.snippet_id, cs.position FROM collection_snippets cs JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = ? AND cs.position > ? AND s.expires > ? ORDER BY cs.position LIMIT 1`
+	if up {
+		stmt = `SELECT cs.snippet_id, cs.position FROM collection_snippets cs JOIN snippets s ON s.id = cs.snippet_id
+		WHERE cs.collection_id = ? AND cs.position < ? AND s.expires > ? ORDER BY cs.position DESC LIMIT 1`
+	}
+
+	var otherID, otherPosition int
+
+	err = tx.QueryRowContext(ctx, stmt, id, position, now()).Scan(&otherID, &otherPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, otherPosition, id, snippetID)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, position, id, otherID)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	return models.ContextError(ctx, tx.Commit())
+}
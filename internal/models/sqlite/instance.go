@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type InstanceModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *InstanceModel) Stats(ctx context.Context) (*models.Stats, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM users WHERE disabled),
+		(SELECT COUNT(*) FROM users WHERE role = ?1),
+		(SELECT COUNT(*) FROM users WHERE role = ?2),
+		(SELECT COUNT(*) FROM snippets),
+		(SELECT COUNT(*) FROM snippets WHERE expires <= ?3),
+		(SELECT COUNT(*) FROM snippets WHERE hidden),
+		(SELECT COUNT(DISTINCT snippet_id) FROM snippet_reports WHERE resolved IS NULL),
+		(SELECT COUNT(*) FROM sessions WHERE expiry > ?3)`
+
+	s := &models.Stats{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, models.RoleModerator, models.RoleAdmin, now()).Scan(&s.Users, &s.DisabledUsers,
+		&s.Moderators, &s.Admins, &s.Snippets, &s.ExpiredSnippets, &s.HiddenSnippets, &s.OpenReports, &s.Sessions)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+func (m *InstanceModel) PurgeSessions(ctx context.Context) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM sessions WHERE expiry <= ?`
+
+	n, err := rowsAffected(m.DB.ExecContext(ctx, stmt, now()))
+	return n, models.ContextError(ctx, err)
+}
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type LoginAttemptModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Emails are lower-cased so that changing the case of an address doesn't reset its count.
+func (m *LoginAttemptModel) Failures(ctx context.Context, email, ip string, since time.Time) (*models.LoginFailures, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `SELECT COUNT(*) FILTER (WHERE email = ?1), COUNT(*) FILTER (WHERE ip = ?2),
+	MAX(created) FILTER (WHERE email = ?1), MAX(created) FILTER (WHERE ip = ?2)
+	FROM login_attempts WHERE created > ?3 AND (email = ?1 OR ip = ?2)`
+
+	f := &models.LoginFailures{}
+	var emailLast, ipLast sql.NullString
+
+	err := m.DB.QueryRowContext(ctx, stmt, email, ip, since.UTC()).Scan(&f.Email, &f.IP, &emailLast, &ipLast)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	if emailLast.Valid {
+		f.EmailLast, err = parseTime(emailLast.String)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+	}
+	if ipLast.Valid {
+		f.IPLast, err = parseTime(ipLast.String)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+	}
+
+	return f, nil
+}
+
+func (m *LoginAttemptModel) RecordFailure(ctx context.Context, email, ip string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `INSERT INTO login_attempts (email, ip, created) VALUES (?, ?, ?)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email, ip, now())
+	return models.ContextError(ctx, err)
+}
+
+func (m *LoginAttemptModel) Reset(ctx context.Context, email string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `DELETE FROM login_attempts WHERE email = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email)
+	return models.ContextError(ctx, err)
+}
+
+func (m *LoginAttemptModel) RecordLockout(ctx context.Context, email, ip string, failures int, until time.Time) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	email = strings.ToLower(email)
+
+	stmt := `INSERT INTO login_lockouts (email, ip, failures, locked_until, created) VALUES (?, ?, ?, ?, ?)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email, ip, failures, until.UTC(), now())
+	return models.ContextError(ctx, err)
+}
+
+func (m *LoginAttemptModel) Purge(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	attempts, err := rowsAffected(tx.ExecContext(ctx, `DELETE FROM login_attempts WHERE created < ?`, before.UTC()))
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	lockouts, err := rowsAffected(tx.ExecContext(ctx, `DELETE FROM login_lockouts WHERE locked_until < ?`, before.UTC()))
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	return attempts + lockouts, models.ContextError(ctx, tx.Commit())
+}
@@ -0,0 +1,304 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type OrganizationModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *OrganizationModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	created := now()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO organizations (name, created) VALUES (?, ?)`, name, created)
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role, created) VALUES (?, ?, ?, ?)`,
+		id, ownerID, models.OrgRoleOwner, created)
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	return int(id), models.ContextError(ctx, tx.Commit())
+}
+
+func (m *OrganizationModel) Get(ctx context.Context, id int) (*models.Organization, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, name, created FROM organizations WHERE id = ?`
+
+	o := &models.Organization{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&o.ID, &o.Name, &o.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return o, nil
+}
+
+func (m *OrganizationModel) ByUser(ctx context.Context, userID int) ([]*models.Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.user_id = ? ORDER BY o.name, o.id`
+
+	return m.queryMembers(ctx, stmt, userID)
+}
+
+func (m *OrganizationModel) Members(ctx context.Context, id int) ([]*models.Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.org_id = ? ORDER BY u.name, u.id`
+
+	return m.queryMembers(ctx, stmt, id)
+}
+
+func (m *OrganizationModel) Member(ctx context.Context, id, userID int) (*models.Membership, error) {
+	stmt := `SELECT o.id, o.name, u.id, u.name, u.email, om.role, om.created
+	FROM organization_members om JOIN organizations o ON o.id = om.org_id JOIN users u ON u.id = om.user_id
+	WHERE om.org_id = ? AND om.user_id = ?`
+
+	members, err := m.queryMembers(ctx, stmt, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return members[0], nil
+}
+
+func (m *OrganizationModel) SetRole(ctx context.Context, id, userID int, role string) error {
+	stmt := `UPDATE organization_members SET role = ? WHERE org_id = ? AND user_id = ?`
+
+	if role == models.OrgRoleOwner {
+		ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+
+		return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, role, id, userID)))
+	}
+
+	return m.changeOwner(ctx, id, userID, stmt, role, id, userID)
+}
+
+func (m *OrganizationModel) RemoveMember(ctx context.Context, id, userID int) error {
+	stmt := `DELETE FROM organization_members WHERE org_id = ? AND user_id = ?`
+
+	return m.changeOwner(ctx, id, userID, stmt, id, userID)
+}
+
+// changeOwner relies on the immediate transactions of the connection so that two
+// concurrent changes can't both see another owner, SQLite has no SELECT ... FOR UPDATE.
+func (m *OrganizationModel) changeOwner(ctx context.Context, id, userID int, stmt string, args ...any) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var owners int
+	var owner bool
+
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(MAX(user_id = ?), false) FROM organization_members
+	WHERE org_id = ? AND role = ?`, userID, id, models.OrgRoleOwner).Scan(&owners, &owner)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	if owners == 1 && owner {
+		return models.ErrLastOwner
+	}
+
+	err = expectOneRow(tx.ExecContext(ctx, stmt, args...))
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	return models.ContextError(ctx, tx.Commit())
+}
+
+// Invite works out the expiry in Go, like SnippetModel.Insert.
+func (m *OrganizationModel) Invite(ctx context.Context, id int, email, role string, invitedBy int) (string, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	token, hash, err := models.NewInvitationToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO organization_invitations (org_id, email, role, token_hash, invited_by, created, expires)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (org_id, email) DO UPDATE SET role = excluded.role, token_hash = excluded.token_hash,
+	invited_by = excluded.invited_by, created = excluded.created, expires = excluded.expires`
+
+	created := now()
+
+	_, err = m.DB.ExecContext(ctx, stmt, id, email, role, hash, invitedBy, created, created.AddDate(0, 0, models.InvitationDays))
+	if err != nil {
+		if isConstraintError(err, sqlite3.ErrConstraintForeignKey) {
+			return "", models.ErrNoRecord
+		}
+		return "", models.ContextError(ctx, err)
+	}
+
+	return token, nil
+}
+
+func (m *OrganizationModel) Invitation(ctx context.Context, token string) (*models.Invitation, error) {
+	stmt := `SELECT i.id, i.org_id, o.name, i.email, i.role, COALESCE(i.invited_by, 0), i.created, i.expires
+	FROM organization_invitations i JOIN organizations o ON o.id = i.org_id
+	WHERE i.token_hash = ? AND i.expires > ?`
+
+	invitations, err := m.queryInvitations(ctx, stmt, models.HashInvitationToken(token), now())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invitations) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return invitations[0], nil
+}
+
+func (m *OrganizationModel) Invitations(ctx context.Context, id int) ([]*models.Invitation, error) {
+	stmt := `SELECT i.id, i.org_id, o.name, i.email, i.role, COALESCE(i.invited_by, 0), i.created, i.expires
+	FROM organization_invitations i JOIN organizations o ON o.id = i.org_id
+	WHERE i.org_id = ? AND i.expires > ? ORDER BY i.email`
+
+	return m.queryInvitations(ctx, stmt, id, now())
+}
+
+// AcceptInvitation relies on the immediate transactions of the connection so that an
+// invitation is only accepted once, SQLite has no SELECT ... FOR UPDATE.
+func (m *OrganizationModel) AcceptInvitation(ctx context.Context, token string, userID int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var id, orgID int
+	var role string
+
+	created := now()
+
+	err = tx.QueryRowContext(ctx, `SELECT id, org_id, role FROM organization_invitations WHERE token_hash = ? AND expires > ?`,
+		models.HashInvitationToken(token), created).Scan(&id, &orgID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role, created) VALUES (?, ?, ?, ?)
+	ON CONFLICT DO NOTHING`, orgID, userID, role, created)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM organization_invitations WHERE id = ?`, id)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	return models.ContextError(ctx, tx.Commit())
+}
+
+func (m *OrganizationModel) queryMembers(ctx context.Context, stmt string, args ...any) ([]*models.Membership, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	members := []*models.Membership{}
+
+	for rows.Next() {
+		om := &models.Membership{}
+
+		err = rows.Scan(&om.OrgID, &om.OrgName, &om.UserID, &om.UserName, &om.Email, &om.Role, &om.Joined)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		members = append(members, om)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return members, nil
+}
+
+func (m *OrganizationModel) queryInvitations(ctx context.Context, stmt string, args ...any) ([]*models.Invitation, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	invitations := []*models.Invitation{}
+
+	for rows.Next() {
+		i := &models.Invitation{}
+
+		err = rows.Scan(&i.ID, &i.OrgID, &i.OrgName, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expires)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return invitations, nil
+}
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type ReportModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reason string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO snippet_reports (snippet_id, reporter_id, reason, created) VALUES (?, ?, ?, ?)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, snippetID, reporterID, reason, now())
+	if err != nil {
+		if isConstraintError(err, sqlite3.ErrConstraintForeignKey) {
+			return models.ErrNoRecord
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	return nil
+}
+
+// Queue collects the reasons with json_group_array, SQLite has no array type.
+func (m *ReportModel) Queue(ctx context.Context) ([]*models.ReportedSnippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.slug, s.title, s.hidden, json_group_array(r.reason ORDER BY r.created), MAX(r.created)
+	FROM snippet_reports r JOIN snippets s ON s.id = r.snippet_id
+	WHERE r.resolved IS NULL
+	GROUP BY s.id ORDER BY MAX(r.created) DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	queue := []*models.ReportedSnippet{}
+
+	for rows.Next() {
+		rs := &models.ReportedSnippet{}
+		var reasons, lastReported string
+
+		err = rows.Scan(&rs.SnippetID, &rs.Slug, &rs.Title, &rs.Hidden, &reasons, &lastReported)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		err = json.Unmarshal([]byte(reasons), &rs.Reasons)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		rs.LastReported, err = parseTime(lastReported)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		queue = append(queue, rs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return queue, nil
+}
+
+func (m *ReportModel) Moderate(ctx context.Context, snippetID, moderatorID int, action string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var stmt string
+
+	switch action {
+	case models.ModerationHide:
+		stmt = `UPDATE snippets SET hidden = true WHERE id = ?`
+	case models.ModerationRestore:
+		stmt = `UPDATE snippets SET hidden = false WHERE id = ?`
+	case models.ModerationDelete:
+		stmt = `DELETE FROM snippets WHERE id = ?`
+	default:
+		return fmt.Errorf("models: unknown moderation action %q", action)
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	err = expectOneRow(tx.ExecContext(ctx, stmt, snippetID))
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	// Deleting the snippet already removed its reports through the foreign key
+	if action != models.ModerationDelete {
+		stmt = `UPDATE snippet_reports SET resolved = ? WHERE snippet_id = ? AND resolved IS NULL`
+		_, err = tx.ExecContext(ctx, stmt, now(), snippetID)
+		if err != nil {
+			return models.ContextError(ctx, err)
+		}
+	}
+
+	stmt = `INSERT INTO moderation_actions (snippet_id, moderator_id, action, created) VALUES (?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, stmt, snippetID, moderatorID, action, now())
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	return models.ContextError(ctx, tx.Commit())
+}
+
+func (m *ReportModel) Log(ctx context.Context, limit int) ([]*models.ModerationAction, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT a.id, a.snippet_id, COALESCE(u.name, ''), a.action, a.created
+	FROM moderation_actions a LEFT JOIN users u ON u.id = a.moderator_id
+	ORDER BY a.id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	actions := []*models.ModerationAction{}
+
+	for rows.Next() {
+		a := &models.ModerationAction{}
+
+		err = rows.Scan(&a.ID, &a.SnippetID, &a.ModeratorName, &a.Action, &a.Created)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		actions = append(actions, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// SessionStore is an scs.Store and scs.IterableStore on the sessions table, in the
+// manner of github.com/alexedwards/scs/postgresstore.
+type SessionStore struct {
+	db *sql.DB
+	// Gets the errors of the cleanup, which has no caller to return them to
+	logger      *slog.Logger
+	stopCleanup chan bool
+}
+
+// NewSessionStore returns a store that deletes the expired sessions every 5 minutes.
+// The logger may be nil.
+func NewSessionStore(db *sql.DB, logger *slog.Logger) *SessionStore {
+	return NewSessionStoreWithCleanupInterval(db, logger, 5*time.Minute)
+}
+
+// NewSessionStoreWithCleanupInterval returns a store that deletes the expired sessions
+// at the given interval, or never if it is zero.
+func NewSessionStoreWithCleanupInterval(db *sql.DB, logger *slog.Logger, cleanupInterval time.Duration) *SessionStore {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	s := &SessionStore{db: db, logger: logger}
+	if cleanupInterval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(cleanupInterval)
+	}
+	return s
+}
+
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := s.db.QueryRow(`SELECT data FROM sessions WHERE token = ? AND expiry > ?`, token, now()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	stmt := `INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?)
+	ON CONFLICT (token) DO UPDATE SET data = excluded.data, expiry = excluded.expiry`
+
+	_, err := s.db.Exec(stmt, token, b, expiry.UTC())
+	return err
+}
+
+func (s *SessionStore) Delete(token string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+func (s *SessionStore) All() (map[string][]byte, error) {
+	rows, err := s.db.Query(`SELECT token, data FROM sessions WHERE expiry > ?`, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+
+	for rows.Next() {
+		var token string
+		var data []byte
+
+		err = rows.Scan(&token, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[token] = data
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ticker.C:
+			_, err := s.db.Exec(`DELETE FROM sessions WHERE expiry <= ?`, now())
+			if err != nil {
+				s.logger.Error("session cleanup", "error", err.Error())
+			}
+		case <-s.stopCleanup:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// StopCleanup stops the cleanup goroutine, it must not be called more than once.
+func (s *SessionStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
@@ -0,0 +1,301 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type SnippetModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Insert works out the expiry in Go, since SQLite has no interval arithmetic, and takes
+// the id from LastInsertId rather than RETURNING.
+func (m *SnippetModel) Insert(ctx context.Context, userID, orgID int, title string, content string, contentType string, expires int) (int, string, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO snippets (title, content, content_type, created, updated, expires, user_id, slug, org_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, 0))`
+
+	created := now()
+
+	return models.InsertWithSlug(func(slug string) (int, error) {
+		result, err := m.DB.ExecContext(ctx, stmt, title, content, contentType, created, created, created.AddDate(0, 0, expires), userID, slug, orgID)
+		if err != nil {
+			// The only unique constraint on snippets is snippets_uc_slug
+			if isConstraintError(err, sqlite3.ErrConstraintUnique) {
+				return 0, models.ErrDuplicateSlug
+			}
+			return 0, models.ContextError(ctx, err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, models.ContextError(ctx, err)
+		}
+
+		return int(id), nil
+	})
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, contentType string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE snippets SET title = ?1, content = ?2, content_type = ?3, updated = ?5 WHERE id = ?4 AND expires > ?5`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, title, content, contentType, id, now())))
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > ? AND id = ?`
+
+	s := &models.Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, now(), id).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) GetLegacy(ctx context.Context, id int) (*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > ? AND id = ? AND legacy`
+
+	s := &models.Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, now(), id).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > ? AND slug = ?`
+
+	s := &models.Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, now(), slug).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE expires > ? AND NOT hidden ORDER BY id DESC LIMIT 10`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, now())
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, limit int) ([]*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE user_id = ? AND expires > ? AND NOT hidden ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, now(), limit)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) ByOrg(ctx context.Context, orgID int, limit int) ([]*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE org_id = ? AND expires > ? AND NOT hidden ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, orgID, now(), limit)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// List matches with LIKE, which SQLite already compares case insensitively for ASCII.
+func (m *SnippetModel) List(ctx context.Context, search string, limit, offset int) ([]*models.Snippet, int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT COUNT(*) OVER(), id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE ?1 = '' OR title LIKE '%' || ?1 || '%' OR content LIKE '%' || ?1 || '%'
+	ORDER BY id DESC LIMIT ?2 OFFSET ?3`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, search, limit, offset)
+	if err != nil {
+		return nil, 0, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	total := 0
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&total, &s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, 0, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, models.ContextError(ctx, err)
+	}
+
+	return snippets, total, nil
+}
+
+func (m *SnippetModel) After(ctx context.Context, afterID, limit int) ([]*models.Snippet, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, slug, title, content, content_type, created, updated, expires, COALESCE(user_id, 0), hidden, COALESCE(org_id, 0) FROM snippets
+	WHERE id > ? ORDER BY id LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, afterID, limit)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.ContentType, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.Hidden, &s.OrgID)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+func (m *SnippetModel) DeleteExpired(ctx context.Context) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE expires <= ?`
+
+	n, err := rowsAffected(m.DB.ExecContext(ctx, stmt, now()))
+	return n, models.ContextError(ctx, err)
+}
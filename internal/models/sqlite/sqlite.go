@@ -0,0 +1,69 @@
+// Package sqlite implements the model interfaces of internal/models, and a session
+// store for github.com/alexedwards/scs, on SQLite through github.com/mattn/go-sqlite3.
+// It is meant for single node deployments that don't want to run PostgreSQL.
+//
+// SQLite has no NOW(), so every timestamp is passed in from Go in UTC. The driver
+// writes a time.Time as text in a single format, which keeps the comparisons in the
+// queries correct as plain string comparisons.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+// now is the current time as it is stored in the database.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// parseTime reads a timestamp that lost its column type in an expression like MAX(created),
+// which makes the driver return it as text.
+func parseTime(s string) (time.Time, error) {
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		t, err := time.ParseInLocation(format, s, time.UTC)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("sqlite: invalid timestamp " + s)
+}
+
+// isConstraintError reports whether err is the violation of a constraint, like
+// sqlite3.ErrConstraintUnique or sqlite3.ErrConstraintForeignKey.
+func isConstraintError(err error, code sqlite3.ErrNoExtended) bool {
+	var sqliteError sqlite3.Error
+	return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == code
+}
+
+// expectOneRow turns the result of an UPDATE or DELETE that matched no rows into models.ErrNoRecord.
+func expectOneRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func rowsAffected(result sql.Result, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
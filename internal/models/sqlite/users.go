@@ -0,0 +1,229 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+const userColumns = `id, name, email, created, role, disabled, password_reset_required`
+
+// scanUser reads a row of userColumns, ctx is the context the row was queried with.
+func scanUser(ctx context.Context, row interface{ Scan(...any) error }) (*models.User, error) {
+	u := &models.User{}
+
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Role, &u.Disabled, &u.PasswordResetRequired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return u, nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	return scanUser(ctx, m.DB.QueryRowContext(ctx, stmt, id))
+}
+
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+
+	return scanUser(ctx, m.DB.QueryRowContext(ctx, stmt, email))
+}
+
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var id int
+	var hashedPassword []byte
+	var disabled bool
+
+	stmt := `SELECT id, hashed_password, disabled FROM users WHERE email = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword, &disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, models.ContextError(ctx, err)
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	// Only tell the user that the account is disabled once they have proven they own it
+	if disabled {
+		return 0, models.ErrAccountDisabled
+	}
+
+	return id, nil
+}
+
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES (?, ?, ?, ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword), now())
+	if err != nil {
+		// The only unique constraint on users is users_uc_email
+		if isConstraintError(err, sqlite3.ErrConstraintUnique) {
+			return models.ErrDuplicateEmail
+		}
+		return models.ContextError(ctx, err)
+	}
+
+	return nil
+}
+
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&exists)
+
+	return exists, models.ContextError(ctx, err)
+}
+
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var currentHashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&currentHashedPassword)
+	if err != nil {
+		return models.ContextError(ctx, err)
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE users SET hashed_password = ?, password_reset_required = false WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
+
+	return models.ContextError(ctx, err)
+}
+
+func (m *UserModel) List(ctx context.Context, search string, limit, offset int) ([]*models.User, int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT COUNT(*) OVER(), ` + userColumns + ` FROM users
+	WHERE ?1 = '' OR name LIKE '%' || ?1 || '%' OR email LIKE '%' || ?1 || '%'
+	ORDER BY id LIMIT ?2 OFFSET ?3`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, search, limit, offset)
+	if err != nil {
+		return nil, 0, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	total := 0
+	users := []*models.User{}
+
+	for rows.Next() {
+		u := &models.User{}
+
+		err = rows.Scan(&total, &u.ID, &u.Name, &u.Email, &u.Created, &u.Role, &u.Disabled, &u.PasswordResetRequired)
+		if err != nil {
+			return nil, 0, models.ContextError(ctx, err)
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, models.ContextError(ctx, err)
+	}
+
+	return users, total, nil
+}
+
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE users SET disabled = ? WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, disabled, id)))
+}
+
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE users SET password_reset_required = true WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE users SET role = ? WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, role, id)))
+}
+
+func (m *UserModel) SetPassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET hashed_password = ?, password_reset_required = true WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, string(hashedPassword), id)))
+}
@@ -0,0 +1,216 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/shtayeb/snippetbox/internal/models"
+)
+
+type WebhookModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, userID, orgID int, url string, events []string) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	secret, err := models.NewWebhookSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO webhooks (user_id, org_id, url, events, secret, created)
+	VALUES (NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?, ?)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, orgID, url, models.JoinEvents(events), secret, now())
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	return int(id), nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE id = ?`
+
+	webhooks, err := m.queryWebhooks(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(webhooks) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return webhooks[0], nil
+}
+
+func (m *WebhookModel) ByUser(ctx context.Context, userID int) ([]*models.Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE user_id = ? ORDER BY id`
+
+	return m.queryWebhooks(ctx, stmt, userID)
+}
+
+func (m *WebhookModel) ByOrg(ctx context.Context, orgID int) ([]*models.Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE org_id = ? ORDER BY id`
+
+	return m.queryWebhooks(ctx, stmt, orgID)
+}
+
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM webhooks WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+func (m *WebhookModel) Subscribers(ctx context.Context, userID, orgID int, event string) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	var err error
+
+	if orgID != 0 {
+		webhooks, err = m.ByOrg(ctx, orgID)
+	} else {
+		webhooks, err = m.ByUser(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(webhooks, func(w *models.Webhook) bool {
+		return !w.Wants(event)
+	}), nil
+}
+
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event, payload string) (int, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, created, updated) VALUES (?, ?, ?, ?, ?)`
+
+	created := now()
+
+	result, err := m.DB.ExecContext(ctx, stmt, webhookID, event, payload, created, created)
+	if err != nil {
+		if isConstraintError(err, sqlite3.ErrConstraintForeignKey) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, models.ContextError(ctx, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+
+	return int(id), nil
+}
+
+func (m *WebhookModel) RecordAttempt(ctx context.Context, deliveryID, statusCode int, errMsg string) error {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE webhook_deliveries SET status_code = ?, error = ?, attempts = attempts + 1, updated = ? WHERE id = ?`
+
+	return models.ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, statusCode, errMsg, now(), deliveryID)))
+}
+
+func (m *WebhookModel) Delivery(ctx context.Context, id int) (*models.Delivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status_code, error, attempts, created, updated
+	FROM webhook_deliveries WHERE id = ?`
+
+	deliveries, err := m.queryDeliveries(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
+	return deliveries[0], nil
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*models.Delivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status_code, error, attempts, created, updated
+	FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`
+
+	return m.queryDeliveries(ctx, stmt, webhookID, limit)
+}
+
+func (m *WebhookModel) queryWebhooks(ctx context.Context, stmt string, args ...any) ([]*models.Webhook, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+
+	for rows.Next() {
+		w := &models.Webhook{}
+		var events string
+
+		err = rows.Scan(&w.ID, &w.UserID, &w.OrgID, &w.URL, &events, &w.Secret, &w.Created)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		w.Events = models.SplitEvents(events)
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return webhooks, nil
+}
+
+func (m *WebhookModel) queryDeliveries(ctx context.Context, stmt string, args ...any) ([]*models.Delivery, error) {
+	ctx, cancel := models.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.Delivery{}
+
+	for rows.Next() {
+		d := &models.Delivery{}
+
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.StatusCode, &d.Error, &d.Attempts, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, models.ContextError(ctx, err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+
+	return deliveries, nil
+}
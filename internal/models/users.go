@@ -1,7 +1,10 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"strings"
 	"time"
@@ -11,53 +14,118 @@ import (
 )
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
-	Get(id int) (*User, error)
-	PasswordUpdate(id int, currentPassword, newPassword string) error
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error
+	List(ctx context.Context, search string, limit, offset int) ([]*User, int, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	RequirePasswordReset(ctx context.Context, id int) error
+	SetRole(ctx context.Context, id int, role string) error
+	SetPassword(ctx context.Context, id int, password string) error
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles so that a higher role has every permission of the lower ones.
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
 }
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
+	ID                    int
+	Name                  string
+	Email                 string
+	HashedPassword        []byte
+	Created               time.Time
+	Role                  string
+	Disabled              bool
+	PasswordResetRequired bool
+}
+
+// ValidRole reports whether role is one of the Role constants.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether the user has the given role or a higher one.
+func (u *User) HasRole(role string) bool {
+	rank, ok := roleRanks[role]
+	return ok && roleRanks[u.Role] >= rank
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m *UserModel) Get(id int) (*User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var user User
 
-	stmt := "SELECT id,name,email,created FROM users WHERE id = $1"
-	err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
+	stmt := `SELECT id, name, email, created, role, disabled, password_reset_required
+	FROM users WHERE id = $1`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created,
+		&user.Role, &user.Disabled, &user.PasswordResetRequired)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
 		} else {
-			return nil, err
+			return nil, ContextError(ctx, err)
 		}
 	}
 
 	return &user, nil
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var user User
+
+	stmt := `SELECT id, name, email, created, role, disabled, password_reset_required
+	FROM users WHERE email = $1`
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&user.ID, &user.Name, &user.Email, &user.Created,
+		&user.Role, &user.Disabled, &user.PasswordResetRequired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, ContextError(ctx, err)
+		}
+	}
+
+	return &user, nil
+}
+
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var id int
 	var hashedPassword []byte
+	var disabled bool
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = $1"
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = $1"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
 		} else {
-			return 0, err
+			return 0, ContextError(ctx, err)
 		}
 	}
 
@@ -71,11 +139,19 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		}
 	}
 
+	// Only tell the user that the account is disabled once they have proven they own it
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
 	// The password is correct. Return the user ID
 	return id, nil
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
@@ -83,7 +159,7 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES($1, $2, $3, now())`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword))
 	if err != nil {
 		// If this returns an error, we use the errors.As() function to check whether the error has the type *mysql.MySQLError. If it does, the error will be assigned to the mySQLError variable.
 		// We can then check whether or not the error relates to our users_uc_email key by
@@ -96,28 +172,34 @@ func (m *UserModel) Insert(name, email, password string) error {
 				return ErrDuplicateEmail
 			}
 		}
-		return err
+		return ContextError(ctx, err)
 	}
 
 	return nil
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var exists bool
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = $1)"
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&exists)
 
-	return exists, err
+	return exists, ContextError(ctx, err)
 }
 
-func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var currentHashedPassword []byte
 	stmt := "SELECT hashed_password FROM users WHERE id = $1"
 
-	err := m.DB.QueryRow(stmt, id).Scan(&currentHashedPassword)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&currentHashedPassword)
 	if err != nil {
-		return err
+		return ContextError(ctx, err)
 	}
 
 	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
@@ -134,8 +216,103 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 		return err
 	}
 
-	stmt = "UPDATE users SET hashed_password = $1 WHERE id = $2"
-	_, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+	stmt = "UPDATE users SET hashed_password = $1, password_reset_required = false WHERE id = $2"
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
+
+	return ContextError(ctx, err)
+}
+
+// List returns a page of users whose name or email contains search, along with the
+// total number of matching users.
+func (m *UserModel) List(ctx context.Context, search string, limit, offset int) ([]*User, int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT COUNT(*) OVER(), id, name, email, created, role, disabled, password_reset_required
+	FROM users
+	WHERE $1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+	ORDER BY id LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, search, limit, offset)
+	if err != nil {
+		return nil, 0, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	total := 0
+	users := []*User{}
+
+	for rows.Next() {
+		u := &User{}
+
+		err = rows.Scan(&total, &u.ID, &u.Name, &u.Email, &u.Created, &u.Role, &u.Disabled, &u.PasswordResetRequired)
+		if err != nil {
+			return nil, 0, ContextError(ctx, err)
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, ContextError(ctx, err)
+	}
+
+	return users, total, nil
+}
+
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "UPDATE users SET disabled = $1 WHERE id = $2"
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, disabled, id)))
+}
+
+// RequirePasswordReset makes the user change their password the next time they use the site.
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "UPDATE users SET password_reset_required = true WHERE id = $1"
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "UPDATE users SET role = $1 WHERE id = $2"
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, role, id)))
+}
+
+// NewTemporaryPassword returns a random password of 16 URL safe characters, for an
+// administrator to hand to a user who has to replace it when they log in.
+func NewTemporaryPassword() (string, error) {
+	b := make([]byte, 12)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetPassword replaces the password without checking the current one, and makes the
+// user choose a new one the next time they use the site. It is meant for administrators.
+func (m *UserModel) SetPassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = $1, password_reset_required = true WHERE id = $2"
 
-	return err
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, string(hashedPassword), id)))
 }
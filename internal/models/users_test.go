@@ -1,17 +1,16 @@
-package models
+package models_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
 )
 
 func TestUserModelExists(t *testing.T) {
-	// Skip the test if the "-short" flag is provided when running the test
-	if testing.Short() {
-		t.Skip("models: skipping integration test")
-	}
-
 	tests := []struct {
 		name   string
 		userID int
@@ -34,16 +33,88 @@ func TestUserModelExists(t *testing.T) {
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			db := newTestDB(t)
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				exists, err := store.Users.Exists(ctx, tt.userID)
 
-			m := UserModel{db}
+				assert.Equal(t, exists, tt.want)
+				assert.NilError(t, err)
+			})
+		}
+	})
+}
 
-			exists, err := m.Exists(tt.userID)
+func TestUserModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
 
-			assert.Equal(t, exists, tt.want)
-			assert.NilError(t, err)
-		})
-	}
+		m := store.Users
+
+		err := m.Insert(ctx, "Bob", "bob@example.com", "pa$$word")
+		assert.NilError(t, err)
+
+		err = m.Insert(ctx, "Robert", "bob@example.com", "pa$$word")
+		assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+		bob, err := m.GetByEmail(ctx, "bob@example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, bob.Name, "Bob")
+		assert.Equal(t, bob.Role, models.RoleUser)
+		assert.Equal(t, bob.Created.IsZero(), false)
+
+		_, err = m.GetByEmail(ctx, "nobody@example.com")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		id, err := m.Authenticate(ctx, "bob@example.com", "pa$$word")
+		assert.NilError(t, err)
+		assert.Equal(t, id, bob.ID)
+
+		_, err = m.Authenticate(ctx, "bob@example.com", "wrong")
+		assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+		err = m.PasswordUpdate(ctx, bob.ID, "wrong", "new pa$$word")
+		assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+		assert.NilError(t, m.PasswordUpdate(ctx, bob.ID, "pa$$word", "new pa$$word"))
+
+		_, err = m.Authenticate(ctx, "bob@example.com", "new pa$$word")
+		assert.NilError(t, err)
+
+		assert.NilError(t, m.SetRole(ctx, bob.ID, models.RoleModerator))
+		assert.NilError(t, m.RequirePasswordReset(ctx, bob.ID))
+
+		bob, err = m.Get(ctx, bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, bob.Role, models.RoleModerator)
+		assert.Equal(t, bob.PasswordResetRequired, true)
+
+		// Setting a password as an administrator keeps the reset flag
+		assert.NilError(t, m.SetPassword(ctx, bob.ID, "temporary"))
+
+		bob, err = m.Get(ctx, bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, bob.PasswordResetRequired, true)
+
+		assert.NilError(t, m.SetDisabled(ctx, bob.ID, true))
+
+		_, err = m.Authenticate(ctx, "bob@example.com", "temporary")
+		assert.Equal(t, errors.Is(err, models.ErrAccountDisabled), true)
+
+		err = m.SetDisabled(ctx, 99, true)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		users, total, err := m.List(ctx, "BOB", 10, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, total, 1)
+		assert.Equal(t, users[0].Email, "bob@example.com")
+
+		users, total, err = m.List(ctx, "", 1, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, total, 2)
+		assert.Equal(t, len(users), 1)
+		assert.Equal(t, users[0].ID, bob.ID)
+	})
 }
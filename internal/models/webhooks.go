@@ -0,0 +1,320 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookModelInterface interface {
+	Insert(ctx context.Context, userID, orgID int, url string, events []string) (int, error)
+	Get(ctx context.Context, id int) (*Webhook, error)
+	ByUser(ctx context.Context, userID int) ([]*Webhook, error)
+	ByOrg(ctx context.Context, orgID int) ([]*Webhook, error)
+	Delete(ctx context.Context, id int) error
+	Subscribers(ctx context.Context, userID, orgID int, event string) ([]*Webhook, error)
+	InsertDelivery(ctx context.Context, webhookID int, event, payload string) (int, error)
+	RecordAttempt(ctx context.Context, deliveryID, statusCode int, errMsg string) error
+	Delivery(ctx context.Context, id int) (*Delivery, error)
+	Deliveries(ctx context.Context, webhookID, limit int) ([]*Delivery, error)
+}
+
+// The events of a snippet that webhooks subscribe to
+const (
+	EventSnippetCreated = "snippet.created"
+	EventSnippetUpdated = "snippet.updated"
+	EventSnippetDeleted = "snippet.deleted"
+)
+
+// WebhookEvents lists every event, in the order the forms show them.
+var WebhookEvents = []string{EventSnippetCreated, EventSnippetUpdated, EventSnippetDeleted}
+
+// A webhook secret holds 32 random bytes, as many as an invitation token
+const webhookSecretBytes = 32
+
+// Webhook is a subscription of a URL to the events of the personal snippets of a user,
+// or of the snippets of an organization. Exactly one of UserID and OrgID is set.
+type Webhook struct {
+	ID     int
+	UserID int
+	OrgID  int
+	URL    string
+	Events []string
+	// The key of the HMAC-SHA256 signature of every delivery. It is stored as is, since
+	// it has to be used again, and only shown to those who manage the webhook.
+	Secret  string
+	Created time.Time
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w *Webhook) Wants(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// Delivery is the sending of an event to a webhook, with the outcome of its last attempt.
+type Delivery struct {
+	ID        int
+	WebhookID int
+	Event     string
+	// The JSON body, the same on every attempt
+	Payload string
+	// Status code of the last response, zero if there wasn't any
+	StatusCode int
+	// Why the last attempt got no response, empty if it did
+	Error    string
+	Attempts int
+	Created  time.Time
+	// When the last attempt was made, or the delivery created if none was yet
+	Updated time.Time
+}
+
+// Succeeded reports whether the last attempt got a 2xx response.
+func (d *Delivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// NewWebhookSecret returns a random secret for a new webhook.
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// JoinEvents returns the events of a webhook as they are stored, in one column
+// separated by commas.
+func JoinEvents(events []string) string {
+	return strings.Join(events, ",")
+}
+
+// SplitEvents is the reverse of JoinEvents.
+func SplitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+
+	return strings.Split(events, ",")
+}
+
+type WebhookModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Insert creates a webhook with a new secret, for the user or for the organization:
+// one of userID and orgID is zero.
+func (m *WebhookModel) Insert(ctx context.Context, userID, orgID int, url string, events []string) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	secret, err := NewWebhookSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO webhooks (user_id, org_id, url, events, secret, created)
+	VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5, NOW()) RETURNING id`
+
+	var id int
+
+	err = m.DB.QueryRowContext(ctx, stmt, userID, orgID, url, JoinEvents(events), secret).Scan(&id)
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (*Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE id = $1`
+
+	webhooks, err := m.queryWebhooks(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(webhooks) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return webhooks[0], nil
+}
+
+// ByUser returns the webhooks of the personal snippets of a user, oldest first.
+func (m *WebhookModel) ByUser(ctx context.Context, userID int) ([]*Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE user_id = $1 ORDER BY id`
+
+	return m.queryWebhooks(ctx, stmt, userID)
+}
+
+// ByOrg returns the webhooks of an organization, oldest first.
+func (m *WebhookModel) ByOrg(ctx context.Context, orgID int) ([]*Webhook, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), COALESCE(org_id, 0), url, events, secret, created
+	FROM webhooks WHERE org_id = $1 ORDER BY id`
+
+	return m.queryWebhooks(ctx, stmt, orgID)
+}
+
+// Delete removes a webhook with its deliveries.
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM webhooks WHERE id = $1`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, id)))
+}
+
+// Subscribers returns the webhooks that want event about a snippet: those of the
+// organization of the snippet if orgID isn't zero, else those of the user who owns it.
+func (m *WebhookModel) Subscribers(ctx context.Context, userID, orgID int, event string) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	var err error
+
+	if orgID != 0 {
+		webhooks, err = m.ByOrg(ctx, orgID)
+	} else {
+		webhooks, err = m.ByUser(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(webhooks, func(w *Webhook) bool {
+		return !w.Wants(event)
+	}), nil
+}
+
+// InsertDelivery records a delivery of event to a webhook, before its first attempt. It
+// returns ErrNoRecord if the webhook has been deleted in the meantime.
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event, payload string) (int, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, created, updated)
+	VALUES ($1, $2, $3, NOW(), NOW()) RETURNING id`
+
+	var id int
+
+	err := m.DB.QueryRowContext(ctx, stmt, webhookID, event, payload).Scan(&id)
+	if err != nil {
+		var pgSQLError *pq.Error
+		if errors.As(err, &pgSQLError) && pgSQLError.Code == "23503" {
+			return 0, ErrNoRecord
+		}
+		return 0, ContextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+// RecordAttempt records the outcome of an attempt at a delivery: the status code of
+// the response, or zero and errMsg if there wasn't one.
+func (m *WebhookModel) RecordAttempt(ctx context.Context, deliveryID, statusCode int, errMsg string) error {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE webhook_deliveries SET status_code = $2, error = $3, attempts = attempts + 1, updated = NOW()
+	WHERE id = $1`
+
+	return ContextError(ctx, expectOneRow(m.DB.ExecContext(ctx, stmt, deliveryID, statusCode, errMsg)))
+}
+
+func (m *WebhookModel) Delivery(ctx context.Context, id int) (*Delivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status_code, error, attempts, created, updated
+	FROM webhook_deliveries WHERE id = $1`
+
+	deliveries, err := m.queryDeliveries(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return deliveries[0], nil
+}
+
+// Deliveries returns the latest deliveries of a webhook, newest first.
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*Delivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status_code, error, attempts, created, updated
+	FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2`
+
+	return m.queryDeliveries(ctx, stmt, webhookID, limit)
+}
+
+func (m *WebhookModel) queryWebhooks(ctx context.Context, stmt string, args ...any) ([]*Webhook, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		w := &Webhook{}
+		var events string
+
+		err = rows.Scan(&w.ID, &w.UserID, &w.OrgID, &w.URL, &events, &w.Secret, &w.Created)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		w.Events = SplitEvents(events)
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return webhooks, nil
+}
+
+func (m *WebhookModel) queryDeliveries(ctx context.Context, stmt string, args ...any) ([]*Delivery, error) {
+	ctx, cancel := WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+
+	for rows.Next() {
+		d := &Delivery{}
+
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.StatusCode, &d.Error, &d.Attempts, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, ContextError(ctx, err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ContextError(ctx, err)
+	}
+
+	return deliveries, nil
+}
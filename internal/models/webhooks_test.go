@@ -0,0 +1,116 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shtayeb/snippetbox/internal/assert"
+	"github.com/shtayeb/snippetbox/internal/models"
+	"github.com/shtayeb/snippetbox/internal/storage"
+)
+
+func TestWebhookModel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *storage.Storage) {
+		ctx := context.Background()
+
+		m := store.Webhooks
+
+		orgID, err := store.Organizations.Insert(ctx, "Haiku Club", 1)
+		assert.NilError(t, err)
+
+		id, err := m.Insert(ctx, 1, 0, "https://chat.example.com/hook", []string{models.EventSnippetCreated, models.EventSnippetDeleted})
+		assert.NilError(t, err)
+
+		orgHook, err := m.Insert(ctx, 0, orgID, "https://ci.example.com/hook", models.WebhookEvents)
+		assert.NilError(t, err)
+
+		w, err := m.Get(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, w.UserID, 1)
+		assert.Equal(t, w.OrgID, 0)
+		assert.Equal(t, w.URL, "https://chat.example.com/hook")
+		assert.Equal(t, len(w.Events), 2)
+		assert.Equal(t, w.Wants(models.EventSnippetDeleted), true)
+		assert.Equal(t, w.Wants(models.EventSnippetUpdated), false)
+		assert.Equal(t, len(w.Secret), 64)
+
+		_, err = m.Get(ctx, 99)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// The personal webhooks and those of the organization are kept apart
+		webhooks, err := m.ByUser(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(webhooks), 1)
+		assert.Equal(t, webhooks[0].ID, id)
+
+		webhooks, err = m.ByOrg(ctx, orgID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(webhooks), 1)
+		assert.Equal(t, webhooks[0].ID, orgHook)
+		assert.Equal(t, webhooks[0].UserID, 0)
+
+		// A personal snippet goes to the webhooks of its owner that want the event
+		webhooks, err = m.Subscribers(ctx, 1, 0, models.EventSnippetCreated)
+		assert.NilError(t, err)
+		assert.Equal(t, len(webhooks), 1)
+		assert.Equal(t, webhooks[0].ID, id)
+
+		webhooks, err = m.Subscribers(ctx, 1, 0, models.EventSnippetUpdated)
+		assert.NilError(t, err)
+		assert.Equal(t, len(webhooks), 0)
+
+		// The snippet of an organization only to those of the organization
+		webhooks, err = m.Subscribers(ctx, 1, orgID, models.EventSnippetCreated)
+		assert.NilError(t, err)
+		assert.Equal(t, len(webhooks), 1)
+		assert.Equal(t, webhooks[0].ID, orgHook)
+
+		first, err := m.InsertDelivery(ctx, id, models.EventSnippetCreated, `{"event":"snippet.created"}`)
+		assert.NilError(t, err)
+
+		d, err := m.Delivery(ctx, first)
+		assert.NilError(t, err)
+		assert.Equal(t, d.WebhookID, id)
+		assert.Equal(t, d.Payload, `{"event":"snippet.created"}`)
+		assert.Equal(t, d.Attempts, 0)
+		assert.Equal(t, d.StatusCode, 0)
+
+		assert.NilError(t, m.RecordAttempt(ctx, first, 0, "connection refused"))
+		assert.NilError(t, m.RecordAttempt(ctx, first, 204, ""))
+
+		d, err = m.Delivery(ctx, first)
+		assert.NilError(t, err)
+		assert.Equal(t, d.Attempts, 2)
+		assert.Equal(t, d.StatusCode, 204)
+		assert.Equal(t, d.Error, "")
+		assert.Equal(t, d.Succeeded(), true)
+
+		second, err := m.InsertDelivery(ctx, id, models.EventSnippetDeleted, `{"event":"snippet.deleted"}`)
+		assert.NilError(t, err)
+
+		deliveries, err := m.Deliveries(ctx, id, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(deliveries), 2)
+		assert.Equal(t, deliveries[0].ID, second)
+
+		deliveries, err = m.Deliveries(ctx, id, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(deliveries), 1)
+
+		err = m.RecordAttempt(ctx, 99, 200, "")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// Deleting a webhook deletes its deliveries
+		assert.NilError(t, m.Delete(ctx, id))
+
+		_, err = m.Delivery(ctx, first)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		_, err = m.InsertDelivery(ctx, id, models.EventSnippetCreated, "{}")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Delete(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
@@ -1,6 +1,7 @@
 package validaror
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -83,3 +84,9 @@ func PermittedInt(value int, permittedValues ...int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// WebURL returns true if a value is an absolute http or https URL.
+func WebURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,11 @@
+package migrations
+
+import "embed"
+
+// Files holds the numbered schema migrations of each database backend, applied in
+// order by internal/migrate. The postgres and sqlite directories have the same
+// versions, and every version has an NNNN_name.up.sql file and a matching
+// NNNN_name.down.sql file.
+//
+//go:embed "postgres" "sqlite"
+var Files embed.FS
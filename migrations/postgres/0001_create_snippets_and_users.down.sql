@@ -1,2 +1,3 @@
-DROP TABLE users;
+DROP TABLE sessions;
 DROP TABLE snippets;
+DROP TABLE users;
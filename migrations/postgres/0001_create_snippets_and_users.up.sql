@@ -0,0 +1,28 @@
+CREATE TABLE users (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	created timestamp NOT NULL,
+	-- UserModel.Insert looks for this name to report a duplicate email
+	CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE snippets (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created timestamp NOT NULL,
+	expires timestamp NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+-- The session store of github.com/alexedwards/scs/postgresstore
+CREATE TABLE sessions (
+	token TEXT PRIMARY KEY,
+	data BYTEA NOT NULL,
+	expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions(expiry);
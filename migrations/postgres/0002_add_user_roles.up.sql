@@ -0,0 +1,4 @@
+ALTER TABLE users
+	ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user',
+	ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+DROP TABLE moderation_actions;
+DROP TABLE snippet_reports;
+
+ALTER TABLE snippets
+	DROP COLUMN hidden,
+	DROP COLUMN user_id;
@@ -0,0 +1,23 @@
+ALTER TABLE snippets
+	ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE snippet_reports (
+	id SERIAL PRIMARY KEY,
+	snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+	reporter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	reason TEXT NOT NULL,
+	created timestamp NOT NULL,
+	resolved timestamp
+);
+
+CREATE INDEX idx_snippet_reports_open ON snippet_reports(snippet_id) WHERE resolved IS NULL;
+
+-- The snippet id is not a foreign key so that the log outlives deleted snippets
+CREATE TABLE moderation_actions (
+	id SERIAL PRIMARY KEY,
+	snippet_id INTEGER NOT NULL,
+	moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	action VARCHAR(20) NOT NULL,
+	created timestamp NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Snippets written before content types were added are plain text
+ALTER TABLE snippets ADD COLUMN content_type VARCHAR(20) NOT NULL DEFAULT 'text';
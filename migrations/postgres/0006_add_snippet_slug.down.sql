@@ -0,0 +1,2 @@
+ALTER TABLE snippets DROP COLUMN legacy;
+ALTER TABLE snippets DROP COLUMN slug;
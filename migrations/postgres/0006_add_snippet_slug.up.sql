@@ -0,0 +1,11 @@
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16);
+-- Existing snippets get a random slug like the new ones: 6 random bytes, base64 encoded
+-- with the URL-safe alphabet
+UPDATE snippets SET slug = translate(encode(decode(substr(md5(random()::text || id::text), 1, 12), 'hex'), 'base64'), '+/', '-_');
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+-- SnippetModel.Insert looks for this name to pick another slug
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+-- Only the snippets that had a numeric address before the slugs keep it, as a redirect.
+-- The newer ones don't, so their IDs can't be walked to find their slugs.
+ALTER TABLE snippets ADD COLUMN legacy BOOLEAN NOT NULL DEFAULT false;
+UPDATE snippets SET legacy = true;
@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP COLUMN org_id;
+
+DROP TABLE organization_invitations;
+DROP TABLE organization_members;
+DROP TABLE organizations;
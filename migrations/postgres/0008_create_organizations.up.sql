@@ -0,0 +1,36 @@
+CREATE TABLE organizations (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	created timestamp NOT NULL
+);
+
+-- The role is owner, maintainer or member
+CREATE TABLE organization_members (
+	org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL,
+	created timestamp NOT NULL,
+	PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user ON organization_members(user_id);
+
+-- Only a hash of the token in the invitation link is kept. An address has a single
+-- pending invitation to an organization, inviting it again replaces the link.
+CREATE TABLE organization_invitations (
+	id SERIAL PRIMARY KEY,
+	org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	token_hash BYTEA NOT NULL,
+	invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	created timestamp NOT NULL,
+	expires timestamp NOT NULL,
+	CONSTRAINT organization_invitations_uc_email UNIQUE (org_id, email),
+	CONSTRAINT organization_invitations_uc_token UNIQUE (token_hash)
+);
+
+-- A snippet with an organization is shared by its members, the user_id is who created it
+ALTER TABLE snippets ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_org ON snippets(org_id);
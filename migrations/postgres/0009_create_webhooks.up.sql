@@ -0,0 +1,31 @@
+-- A webhook gets the events of the personal snippets of a user, or of the snippets of
+-- an organization, so exactly one of user_id and org_id is set
+CREATE TABLE webhooks (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+	url VARCHAR(2048) NOT NULL,
+	-- The events it subscribes to, separated by commas
+	events VARCHAR(255) NOT NULL,
+	secret VARCHAR(64) NOT NULL,
+	created timestamp NOT NULL,
+	CONSTRAINT webhooks_owner CHECK ((user_id IS NULL) <> (org_id IS NULL))
+);
+
+CREATE INDEX idx_webhooks_user ON webhooks(user_id);
+CREATE INDEX idx_webhooks_org ON webhooks(org_id);
+
+-- status_code is 0 until a response comes back, error says why it didn't
+CREATE TABLE webhook_deliveries (
+	id SERIAL PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created timestamp NOT NULL,
+	updated timestamp NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id);
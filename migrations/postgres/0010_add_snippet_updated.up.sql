@@ -0,0 +1,5 @@
+-- When a snippet was last edited, the feeds date their entries with it. Snippets that
+-- were never edited have it equal to created.
+ALTER TABLE snippets ADD COLUMN updated timestamp;
+UPDATE snippets SET updated = created;
+ALTER TABLE snippets ALTER COLUMN updated SET NOT NULL;
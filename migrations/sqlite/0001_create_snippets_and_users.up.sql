@@ -0,0 +1,29 @@
+-- Timestamps are written by the application in UTC, as the text that
+-- github.com/mattn/go-sqlite3 uses for time.Time, so they compare correctly as strings.
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	created TIMESTAMP NOT NULL,
+	CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE snippets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+-- The session store in internal/models/sqlite
+CREATE TABLE sessions (
+	token TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	expiry TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions(expiry);
@@ -0,0 +1,18 @@
+CREATE TABLE login_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email VARCHAR(255) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
+
+CREATE TABLE login_lockouts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email VARCHAR(255) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	failures INTEGER NOT NULL,
+	locked_until TIMESTAMP NOT NULL,
+	created TIMESTAMP NOT NULL
+);
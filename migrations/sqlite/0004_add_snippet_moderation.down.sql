@@ -0,0 +1,19 @@
+DROP TABLE moderation_actions;
+DROP TABLE snippet_reports;
+
+-- SQLite can't drop a column with a foreign key, so the table is rebuilt without them
+CREATE TABLE snippets_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL
+);
+
+INSERT INTO snippets_old (id, title, content, created, expires)
+SELECT id, title, content, created, expires FROM snippets;
+
+DROP TABLE snippets;
+ALTER TABLE snippets_old RENAME TO snippets;
+
+CREATE INDEX idx_snippets_created ON snippets(created);
@@ -0,0 +1,22 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE snippets ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE snippet_reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+	reporter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	reason TEXT NOT NULL,
+	created TIMESTAMP NOT NULL,
+	resolved TIMESTAMP
+);
+
+CREATE INDEX idx_snippet_reports_open ON snippet_reports(snippet_id) WHERE resolved IS NULL;
+
+-- The snippet id is not a foreign key so that the log outlives deleted snippets
+CREATE TABLE moderation_actions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	snippet_id INTEGER NOT NULL,
+	moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	action VARCHAR(20) NOT NULL,
+	created TIMESTAMP NOT NULL
+);
@@ -0,0 +1,8 @@
+-- SQLite can't add a NOT NULL column without a default, nor a constraint, to an existing table
+ALTER TABLE snippets ADD COLUMN slug TEXT;
+-- Without base64 in SQLite, existing snippets get 8 random hex characters
+UPDATE snippets SET slug = lower(hex(randomblob(4)));
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets (slug);
+-- Only the snippets that had a numeric address before the slugs keep it, as a redirect
+ALTER TABLE snippets ADD COLUMN legacy BOOLEAN NOT NULL DEFAULT false;
+UPDATE snippets SET legacy = true;
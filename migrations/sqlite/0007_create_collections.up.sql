@@ -0,0 +1,20 @@
+CREATE TABLE collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	title VARCHAR(100) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	visibility VARCHAR(10) NOT NULL DEFAULT 'private',
+	created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_collections_user ON collections(user_id);
+
+-- The snippets of a collection are shown in the order of their position
+CREATE TABLE collection_snippets (
+	collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+	snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet ON collection_snippets(snippet_id);
@@ -0,0 +1,6 @@
+DROP INDEX idx_snippets_org;
+ALTER TABLE snippets DROP COLUMN org_id;
+
+DROP TABLE organization_invitations;
+DROP TABLE organization_members;
+DROP TABLE organizations;
@@ -0,0 +1,37 @@
+CREATE TABLE organizations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(100) NOT NULL,
+	created TIMESTAMP NOT NULL
+);
+
+-- The role is owner, maintainer or member
+CREATE TABLE organization_members (
+	org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL,
+	created TIMESTAMP NOT NULL,
+	PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user ON organization_members(user_id);
+
+-- Only a hash of the token in the invitation link is kept. An address has a single
+-- pending invitation to an organization, inviting it again replaces the link.
+CREATE TABLE organization_invitations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	token_hash BLOB NOT NULL UNIQUE,
+	invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NOT NULL,
+	UNIQUE (org_id, email)
+);
+
+-- A snippet with an organization is shared by its members, the user_id is who created it.
+-- SQLite can't drop a column with a foreign key, which the down migration has to, and
+-- organizations are never deleted, so org_id goes without one.
+ALTER TABLE snippets ADD COLUMN org_id INTEGER;
+
+CREATE INDEX idx_snippets_org ON snippets(org_id);
@@ -0,0 +1,5 @@
+-- When a snippet was last edited, the feeds date their entries with it. Snippets that
+-- were never edited have it equal to created. SQLite can't add a NOT NULL column
+-- without a default, every insert sets it.
+ALTER TABLE snippets ADD COLUMN updated TIMESTAMP;
+UPDATE snippets SET updated = created;
@@ -5,4 +5,45 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// The preview button of the create and edit forms needs JavaScript, so it stays hidden without it
+var previewButton = document.getElementById("preview-button");
+if (previewButton) {
+	var preview = document.getElementById("preview");
+	previewButton.hidden = false;
+
+	previewButton.addEventListener("click", function () {
+		// The form goes URL encoded, with its CSRF token, like a normal submission
+		fetch("/snippet/preview", {
+			method: "POST",
+			body: new URLSearchParams(new FormData(previewButton.form)),
+		}).then(function (response) {
+			if (!response.ok) {
+				throw new Error(response.statusText);
+			}
+			return response.text();
+		}).then(function (fragment) {
+			// The server has already sanitized the fragment
+			preview.innerHTML = fragment;
+			preview.hidden = false;
+		}).catch(function (err) {
+			preview.textContent = "Preview failed: " + err.message;
+			preview.hidden = false;
+		});
+	});
+}
+
+// So does the copy button of the embed code on the snippet page
+var embedCopy = document.getElementById("embed-copy");
+if (embedCopy) {
+	var embedCode = document.getElementById("embed-code");
+	embedCopy.hidden = false;
+
+	embedCopy.addEventListener("click", function () {
+		embedCode.select();
+		navigator.clipboard.writeText(embedCode.value).then(function () {
+			embedCopy.textContent = "Copied";
+		});
+	});
+}